@@ -0,0 +1,380 @@
+package admin
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"strings"
+	"sync"
+	"time"
+
+	app "github.com/casualjim/go-app"
+	cjm "github.com/casualjim/middlewares"
+	metrics "github.com/rcrowley/go-metrics"
+	"github.com/sirupsen/logrus"
+)
+
+// DefaultListen is the address the admin server listens on when none is configured
+const DefaultListen = "localhost:9090"
+
+// The names of the endpoints, as used in the admin.endpoints config
+const (
+	EndpointHealth  = "healthz"
+	EndpointReady   = "readyz"
+	EndpointInfo    = "info"
+	EndpointConfig  = "config"
+	EndpointLoggers = "loggers"
+	EndpointMetrics = "metrics"
+	EndpointPprof   = "pprof"
+)
+
+var allEndpoints = []string{
+	EndpointHealth,
+	EndpointReady,
+	EndpointInfo,
+	EndpointConfig,
+	EndpointLoggers,
+	EndpointMetrics,
+	EndpointPprof,
+}
+
+// settings are the parts of the config that apply to every request
+type settings struct {
+	listen    string
+	token     string
+	endpoints map[string]bool
+}
+
+func readSettings(application app.Application) settings {
+	cfg := application.Config()
+	s := settings{
+		listen:    cfg.GetString("admin.listen"),
+		token:     cfg.GetString("admin.token"),
+		endpoints: make(map[string]bool, len(allEndpoints)),
+	}
+	if s.listen == "" {
+		s.listen = DefaultListen
+	}
+
+	enabled := cfg.GetStringSlice("admin.endpoints")
+	if len(enabled) == 0 {
+		enabled = allEndpoints
+	}
+	for _, ep := range enabled {
+		s.endpoints[strings.ToLower(ep)] = true
+	}
+	return s
+}
+
+// Server is a module that serves the admin endpoints
+type Server struct {
+	app      app.Application
+	lock     sync.Mutex
+	settings settings
+	server   *http.Server
+	listener net.Listener
+}
+
+// New admin server module, add it to an application to serve the admin endpoints
+func New() *Server {
+	return new(Server)
+}
+
+// Name of the module, modules.admin in the config applies to the admin server
+func (s *Server) Name() string {
+	return "admin"
+}
+
+// Addr is the address the server listens on, it is empty when the server isn't started
+func (s *Server) Addr() string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.listener == nil {
+		return ""
+	}
+	return s.listener.Addr().String()
+}
+
+// Init reads the config
+func (s *Server) Init(application app.Application) error {
+	return s.InitContext(context.Background(), application)
+}
+
+// InitContext reads the config
+func (s *Server) InitContext(_ context.Context, application app.Application) error {
+	s.lock.Lock()
+	s.app = application
+	s.settings = readSettings(application)
+	s.lock.Unlock()
+	return nil
+}
+
+// Start listening, a failure to listen is returned, a failure to serve is reported with Fail
+func (s *Server) Start(application app.Application) error {
+	return s.StartContext(context.Background(), application)
+}
+
+// StartContext listens on the configured address
+func (s *Server) StartContext(_ context.Context, _ app.Application) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.listen(s.settings.listen)
+}
+
+// listen on the address and serve in the background, expects the lock to be held
+func (s *Server) listen(addr string) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	srv := &http.Server{Handler: s.handler()}
+	s.server = srv
+	s.listener = ln
+
+	application := s.app
+	go func() {
+		if err := srv.Serve(ln); err != nil && err != http.ErrServerClosed {
+			application.Fail(err)
+		}
+	}()
+	application.Logger().Infof("admin server listening on %s", ln.Addr())
+	return nil
+}
+
+// Stop the server, requests in flight are given the time left in the stop phase
+func (s *Server) Stop(application app.Application) error {
+	return s.StopContext(context.Background(), application)
+}
+
+// StopContext stops the server, waiting for requests in flight until the context is done
+func (s *Server) StopContext(ctx context.Context, _ app.Application) error {
+	s.lock.Lock()
+	srv := s.server
+	s.server = nil
+	s.listener = nil
+	s.lock.Unlock()
+
+	if srv == nil {
+		return nil
+	}
+	return srv.Shutdown(ctx)
+}
+
+// Reload applies the new config, the server moves to the new address when the listen address changed
+func (s *Server) Reload(application app.Application) error {
+	return s.ReloadContext(context.Background(), application)
+}
+
+// ReloadContext applies the new config
+func (s *Server) ReloadContext(ctx context.Context, application app.Application) error {
+	next := readSettings(application)
+
+	s.lock.Lock()
+	prev := s.settings
+	s.settings = next
+	old := s.server
+	if old == nil || prev.listen == next.listen {
+		s.lock.Unlock()
+		return nil
+	}
+	// bind the new address first, when that fails the server keeps running at the old one
+	oldListener := s.listener
+	if err := s.listen(next.listen); err != nil {
+		s.settings.listen = prev.listen
+		s.server = old
+		s.listener = oldListener
+		s.lock.Unlock()
+		return err
+	}
+	s.lock.Unlock()
+
+	return old.Shutdown(ctx)
+}
+
+func (s *Server) current() settings {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.settings
+}
+
+func (s *Server) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/healthz", s.endpoint(EndpointHealth, http.HandlerFunc(s.health)))
+	mux.Handle("/readyz", s.endpoint(EndpointReady, http.HandlerFunc(s.ready)))
+	mux.Handle("/info", s.endpoint(EndpointInfo, http.HandlerFunc(s.info)))
+	mux.Handle("/config", s.endpoint(EndpointConfig, http.HandlerFunc(s.config)))
+	mux.Handle("/loggers", s.endpoint(EndpointLoggers, http.HandlerFunc(s.loggers)))
+	mux.Handle("/loggers/", s.endpoint(EndpointLoggers, http.HandlerFunc(s.logger)))
+	mux.Handle("/metrics", s.endpoint(EndpointMetrics, http.HandlerFunc(s.metrics)))
+	mux.Handle("/debug/pprof/", s.endpoint(EndpointPprof, http.HandlerFunc(pprof.Index)))
+	mux.Handle("/debug/pprof/cmdline", s.endpoint(EndpointPprof, http.HandlerFunc(pprof.Cmdline)))
+	mux.Handle("/debug/pprof/profile", s.endpoint(EndpointPprof, http.HandlerFunc(pprof.Profile)))
+	mux.Handle("/debug/pprof/symbol", s.endpoint(EndpointPprof, http.HandlerFunc(pprof.Symbol)))
+	mux.Handle("/debug/pprof/trace", s.endpoint(EndpointPprof, http.HandlerFunc(pprof.Trace)))
+	return mux
+}
+
+// endpoint serves the handler when the endpoint is enabled and the request carries the token
+func (s *Server) endpoint(name string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		cfg := s.current()
+		if !cfg.endpoints[name] {
+			http.NotFound(rw, r)
+			return
+		}
+		if cfg.token != "" && !authorized(r, cfg.token) {
+			rw.Header().Set("WWW-Authenticate", `Bearer realm="admin"`)
+			http.Error(rw, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(rw, r)
+	})
+}
+
+func authorized(r *http.Request, token string) bool {
+	auth := r.Header.Get("Authorization")
+	const prefix = "Bearer "
+	if !strings.HasPrefix(auth, prefix) {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(auth[len(prefix):]), []byte(token)) == 1
+}
+
+func writeJSON(rw http.ResponseWriter, status int, data interface{}) {
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(status)
+	enc := json.NewEncoder(rw)
+	enc.SetIndent("", "  ")
+	enc.Encode(data)
+}
+
+type healthResponse struct {
+	Status app.HealthStatus  `json:"status"`
+	Checks []app.CheckResult `json:"checks"`
+}
+
+func (s *Server) healthResponse(r *http.Request, kind app.CheckKind) (int, healthResponse) {
+	report := s.app.Health(r.Context())
+
+	resp := healthResponse{Status: report.Live, Checks: []app.CheckResult{}}
+	if kind == app.CheckReadiness {
+		resp.Status = report.Ready
+	}
+	for _, check := range report.Checks {
+		if check.Kind == kind {
+			resp.Checks = append(resp.Checks, check)
+		}
+	}
+
+	if resp.Status == app.HealthDown {
+		return http.StatusServiceUnavailable, resp
+	}
+	return http.StatusOK, resp
+}
+
+func (s *Server) health(rw http.ResponseWriter, r *http.Request) {
+	status, resp := s.healthResponse(r, app.CheckHealth)
+	writeJSON(rw, status, resp)
+}
+
+func (s *Server) ready(rw http.ResponseWriter, r *http.Request) {
+	status, resp := s.healthResponse(r, app.CheckReadiness)
+	writeJSON(rw, status, resp)
+}
+
+// infoResponse is the app info with the active config profile
+type infoResponse struct {
+	cjm.AppInfo
+	Profile string `json:"profile,omitempty"`
+}
+
+func (s *Server) info(rw http.ResponseWriter, _ *http.Request) {
+	writeJSON(rw, http.StatusOK, infoResponse{AppInfo: s.app.Info(), Profile: s.app.Profile()})
+}
+
+func (s *Server) config(rw http.ResponseWriter, _ *http.Request) {
+	cfg := s.app.Config()
+	settings := redactSecrets(cfg.AllSettings(), "", s.app.IsSensitive)
+	writeJSON(rw, http.StatusOK, Redact(settings, cfg.GetStringSlice("admin.redact")...))
+}
+
+type loggerLevel struct {
+	Level string `json:"level"`
+	// Override is true when the level was set at runtime, Expires is set when that override expires
+	Override bool       `json:"override,omitempty"`
+	Expires  *time.Time `json:"expires,omitempty"`
+}
+
+func (s *Server) loggers(rw http.ResponseWriter, _ *http.Request) {
+	reg := s.app.Loggers()
+	overrides := reg.LevelOverrides()
+	levels := make(map[string]loggerLevel)
+	for name, level := range reg.Levels() {
+		ll := loggerLevel{Level: level.String()}
+		if ovr, ok := overrides[name]; ok {
+			ll.Override = true
+			if !ovr.Expires.IsZero() {
+				expires := ovr.Expires
+				ll.Expires = &expires
+			}
+		}
+		levels[name] = ll
+	}
+	writeJSON(rw, http.StatusOK, levels)
+}
+
+type setLevelRequest struct {
+	Level string `json:"level"`
+	TTL   string `json:"ttl"`
+}
+
+// logger changes the level of a single logger:
+//
+//	PUT /loggers/root.orders {"level": "debug", "ttl": "10m"}
+//	DELETE /loggers/root.orders
+func (s *Server) logger(rw http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, "/loggers/")
+	reg := s.app.Loggers()
+
+	switch r.Method {
+	case "PUT", "POST":
+		var req setLevelRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(rw, err.Error(), http.StatusBadRequest)
+			return
+		}
+		level, err := logrus.ParseLevel(req.Level)
+		if err != nil {
+			http.Error(rw, err.Error(), http.StatusBadRequest)
+			return
+		}
+		var ttl time.Duration
+		if req.TTL != "" {
+			if ttl, err = time.ParseDuration(req.TTL); err != nil {
+				http.Error(rw, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		if err := reg.SetLevel(name, level, ttl); err != nil {
+			http.Error(rw, err.Error(), http.StatusNotFound)
+			return
+		}
+		s.app.Logger().Infof("log level of %s set to %s", name, level)
+		rw.WriteHeader(http.StatusNoContent)
+	case "DELETE":
+		reg.ResetLevel(name)
+		s.app.Logger().Infof("log level of %s reset", name)
+		rw.WriteHeader(http.StatusNoContent)
+	default:
+		rw.Header().Set("Allow", "PUT, POST, DELETE")
+		http.Error(rw, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *Server) metrics(rw http.ResponseWriter, _ *http.Request) {
+	rw.Header().Set("Content-Type", "application/json")
+	metrics.WriteJSONOnce(metrics.DefaultRegistry, rw)
+}
@@ -0,0 +1,237 @@
+package admin
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+
+	app "github.com/casualjim/go-app"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func startAdmin(t *testing.T, settings map[string]interface{}) (app.Application, *Server) {
+	application, err := app.New("admin-test")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	application.Config().Set("admin.listen", "127.0.0.1:0")
+	for k, v := range settings {
+		application.Config().Set(k, v)
+	}
+	srv := New()
+	application.Add(srv)
+	if !assert.NoError(t, application.Init()) || !assert.NoError(t, application.Start()) {
+		t.FailNow()
+	}
+	return application, srv
+}
+
+func get(t *testing.T, url, token string) (int, []byte) {
+	req, err := http.NewRequest("GET", url, nil)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	defer resp.Body.Close()
+	body, _ := ioutil.ReadAll(resp.Body)
+	return resp.StatusCode, body
+}
+
+func TestAdmin_Endpoints(t *testing.T) {
+	application, srv := startAdmin(t, map[string]interface{}{"db.password": "hunter2", "db.host": "localhost"})
+	defer application.Stop()
+	base := "http://" + srv.Addr()
+
+	code, body := get(t, base+"/healthz", "")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Contains(t, string(body), `"status": "up"`)
+
+	code, _ = get(t, base+"/readyz", "")
+	assert.Equal(t, http.StatusOK, code)
+
+	code, body = get(t, base+"/info", "")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Contains(t, string(body), "admin-test")
+
+	code, body = get(t, base+"/config", "")
+	if assert.Equal(t, http.StatusOK, code) {
+		var cfg map[string]map[string]interface{}
+		if assert.NoError(t, json.Unmarshal(body, &cfg)) {
+			assert.Equal(t, Redacted, cfg["db"]["password"])
+			assert.Equal(t, "localhost", cfg["db"]["host"])
+		}
+	}
+
+	code, body = get(t, base+"/loggers", "")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Contains(t, string(body), `"root"`)
+
+	code, _ = get(t, base+"/metrics", "")
+	assert.Equal(t, http.StatusOK, code)
+
+	code, _ = get(t, base+"/debug/pprof/", "")
+	assert.Equal(t, http.StatusOK, code)
+}
+
+func TestAdmin_LogLevels(t *testing.T) {
+	application, srv := startAdmin(t, nil)
+	defer application.Stop()
+	base := "http://" + srv.Addr()
+
+	send := func(method, path, body string) int {
+		req, err := http.NewRequest(method, base+path, strings.NewReader(body))
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	assert.Equal(t, http.StatusNoContent, send("PUT", "/loggers/root", `{"level":"debug","ttl":"1h"}`))
+	assert.Equal(t, logrus.DebugLevel, application.Loggers().Levels()["root"])
+
+	_, body := get(t, base+"/loggers", "")
+	assert.Contains(t, string(body), `"override": true`)
+	assert.Contains(t, string(body), `"expires"`)
+
+	assert.Equal(t, http.StatusNotFound, send("PUT", "/loggers/root.unknown", `{"level":"debug"}`))
+	assert.Equal(t, http.StatusBadRequest, send("PUT", "/loggers/root", `{"level":"loud"}`))
+	assert.Equal(t, http.StatusBadRequest, send("PUT", "/loggers/root", `{"level":"info","ttl":"soon"}`))
+	assert.Equal(t, http.StatusMethodNotAllowed, send("GET", "/loggers/root", ""))
+
+	assert.Equal(t, http.StatusNoContent, send("DELETE", "/loggers/root", ""))
+	assert.Empty(t, application.Loggers().LevelOverrides())
+}
+
+func TestAdmin_Token(t *testing.T) {
+	application, srv := startAdmin(t, map[string]interface{}{"admin.token": "s3cret"})
+	defer application.Stop()
+	base := "http://" + srv.Addr()
+
+	code, _ := get(t, base+"/info", "")
+	assert.Equal(t, http.StatusUnauthorized, code)
+	code, _ = get(t, base+"/info", "wrong")
+	assert.Equal(t, http.StatusUnauthorized, code)
+	code, _ = get(t, base+"/info", "s3cret")
+	assert.Equal(t, http.StatusOK, code)
+}
+
+func TestAdmin_Reload(t *testing.T) {
+	application, srv := startAdmin(t, nil)
+	defer application.Stop()
+	first := srv.Addr()
+
+	application.Config().Set("admin.endpoints", []string{"healthz"})
+	assert.NoError(t, srv.Reload(application))
+	code, _ := get(t, "http://"+first+"/info", "")
+	assert.Equal(t, http.StatusNotFound, code)
+	code, _ = get(t, "http://"+first+"/healthz", "")
+	assert.Equal(t, http.StatusOK, code)
+
+	// a different address moves the server
+	application.Config().Set("admin.listen", "localhost:0")
+	assert.NoError(t, srv.Reload(application))
+	second := srv.Addr()
+	assert.NotEqual(t, first, second)
+	code, _ = get(t, "http://"+second+"/healthz", "")
+	assert.Equal(t, http.StatusOK, code)
+	_, err := http.Get("http://" + first + "/healthz")
+	assert.Error(t, err)
+}
+
+func TestAdmin_Info(t *testing.T) {
+	settings := app.EmbeddedSource("settings.yaml", []byte("admin:\n  listen: 127.0.0.1:0\n"))
+	application, err := app.NewWithOptions("admin-test",
+		app.WithConfigSource(settings, app.PriorityDefaults),
+		app.WithProfile("staging"),
+		app.WithSignalHandling(false),
+	)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer application.Close()
+	srv := New()
+	application.Add(srv)
+	if !assert.NoError(t, application.Init()) || !assert.NoError(t, application.Start()) {
+		return
+	}
+
+	code, body := get(t, "http://"+srv.Addr()+"/info", "")
+	if assert.Equal(t, http.StatusOK, code) {
+		var info map[string]interface{}
+		if assert.NoError(t, json.Unmarshal(body, &info)) {
+			assert.Equal(t, "admin-test", info["name"])
+			assert.Equal(t, "staging", info["profile"])
+		}
+	}
+}
+
+func TestAdmin_Secrets(t *testing.T) {
+	os.Setenv("ADMIN_TEST_DB_PASS", "hunter2")
+	defer os.Unsetenv("ADMIN_TEST_DB_PASS")
+	settings := app.EmbeddedSource("settings.yaml", []byte(`
+admin:
+  listen: 127.0.0.1:0
+db:
+  url: postgres://orders:${env:ADMIN_TEST_DB_PASS}@localhost/orders
+  host: localhost
+`))
+	application, err := app.NewWithOptions("admin-test", app.WithConfigSource(settings, app.PriorityDefaults), app.WithSignalHandling(false))
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer application.Close()
+	srv := New()
+	application.Add(srv)
+	if !assert.NoError(t, application.Init()) || !assert.NoError(t, application.Start()) {
+		return
+	}
+
+	code, body := get(t, "http://"+srv.Addr()+"/config", "")
+	if assert.Equal(t, http.StatusOK, code) {
+		assert.NotContains(t, string(body), "hunter2")
+		var cfg map[string]map[string]interface{}
+		if assert.NoError(t, json.Unmarshal(body, &cfg)) {
+			assert.Equal(t, Redacted, cfg["db"]["url"])
+			assert.Equal(t, "localhost", cfg["db"]["host"])
+		}
+	}
+}
+
+func TestRedact(t *testing.T) {
+	settings := map[string]interface{}{
+		"name": "orders",
+		"db": map[interface{}]interface{}{
+			"Password": "hunter2",
+			"replicas": []interface{}{
+				map[string]interface{}{"host": "a", "api_key": "k"},
+			},
+		},
+		"stripe": map[string]interface{}{"account": "acct"},
+	}
+	redacted := Redact(settings, "account")
+	assert.Equal(t, map[string]interface{}{
+		"name": "orders",
+		"db": map[string]interface{}{
+			"Password": Redacted,
+			"replicas": []interface{}{
+				map[string]interface{}{"host": "a", "api_key": Redacted},
+			},
+		},
+		"stripe": map[string]interface{}{"account": Redacted},
+	}, redacted)
+}
@@ -0,0 +1,33 @@
+/*
+Package admin provides a module that serves operational endpoints for an application on a separate port.
+
+	application.Add(admin.New())
+
+The server is configured through the application config, changes are picked up when the config is reloaded.
+
+	admin:
+	  listen: localhost:9090
+	  token: s3cret
+	  endpoints:
+	    - healthz
+	    - readyz
+	    - info
+	    - config
+	    - loggers
+	    - metrics
+	    - pprof
+
+When a token is configured, every request needs to send it as a bearer token in the Authorization header.
+All endpoints are served when none are listed.
+
+Endpoints:
+
+	/healthz       the health checks of the modules, 503 when the application is down
+	/readyz        the readiness checks of the modules, 503 when the application isn't ready
+	/info          the name, version and pid of the application, and the active config profile
+	/config        the config, with the values of sensitive keys and resolved secrets replaced
+	/loggers       the level of every known logger
+	/metrics       the go-metrics registry the tracer reports into
+	/debug/pprof/  the runtime profiles
+*/
+package admin
@@ -0,0 +1,95 @@
+package admin
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Redacted replaces the values of sensitive keys in the config
+const Redacted = "******"
+
+// sensitive parts of key names, a key is redacted when its last element contains one of these
+var sensitive = []string{"password", "passwd", "secret", "token", "credential", "apikey", "api_key", "private"}
+
+// Redact returns a copy of the settings, with the values of sensitive keys replaced.
+// Keys are sensitive when their name contains password, secret, token and the like,
+// the extra patterns add to that list.
+func Redact(settings map[string]interface{}, extra ...string) map[string]interface{} {
+	patterns := sensitive
+	for _, p := range extra {
+		patterns = append(patterns[:len(patterns):len(patterns)], strings.ToLower(p))
+	}
+	return redactMap(settings, patterns)
+}
+
+func isSensitive(key string, patterns []string) bool {
+	k := strings.ToLower(key)
+	for _, p := range patterns {
+		if strings.Contains(k, p) {
+			return true
+		}
+	}
+	return false
+}
+
+func redactMap(settings map[string]interface{}, patterns []string) map[string]interface{} {
+	res := make(map[string]interface{}, len(settings))
+	for k, v := range settings {
+		if isSensitive(k, patterns) {
+			res[k] = Redacted
+			continue
+		}
+		res[k] = redactValue(v, patterns)
+	}
+	return res
+}
+
+// redactValue walks nested values, yaml decodes nested maps with interface keys which json can't encode
+func redactValue(value interface{}, patterns []string) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		return redactMap(v, patterns)
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, vv := range v {
+			m[fmt.Sprint(k)] = vv
+		}
+		return redactMap(m, patterns)
+	case []interface{}:
+		res := make([]interface{}, len(v))
+		for i, vv := range v {
+			res[i] = redactValue(vv, patterns)
+		}
+		return res
+	default:
+		return v
+	}
+}
+
+// redactSecrets returns a copy of the settings, with the values that contain a resolved secret replaced
+func redactSecrets(settings map[string]interface{}, prefix string, isSecret func(string) bool) map[string]interface{} {
+	res := make(map[string]interface{}, len(settings))
+	for k, v := range settings {
+		key := strings.ToLower(k)
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+		if !isSecret(key) {
+			res[k] = v
+			continue
+		}
+		switch m := v.(type) {
+		case map[string]interface{}:
+			res[k] = redactSecrets(m, key, isSecret)
+		case map[interface{}]interface{}:
+			sm := make(map[string]interface{}, len(m))
+			for mk, mv := range m {
+				sm[fmt.Sprint(mk)] = mv
+			}
+			res[k] = redactSecrets(sm, key, isSecret)
+		default:
+			res[k] = Redacted
+		}
+	}
+	return res
+}
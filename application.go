@@ -1,17 +1,17 @@
 package app
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"io"
 	"log"
-	"net/url"
 	"os"
-	"os/signal"
 	"path/filepath"
-	goruntime "runtime"
+	"strconv"
 	"strings"
 	"sync"
-	"syscall"
+	"sync/atomic"
 
 	"github.com/casualjim/go-app/logging"
 	"github.com/casualjim/go-app/tracing"
@@ -49,7 +49,9 @@ type Key string
 // Application is an application level context package
 // It can be used as a kind of dependency injection container
 type Application interface {
-	// Add modules to the application context
+	// Add modules to the application context.
+	// Modules that implement Dependent are initialized after the modules that provide their required keys,
+	// all other modules are initialized in the order they were added.
 	Add(...Module) error
 
 	// Get the module at the specified key, thread-safe
@@ -61,6 +63,13 @@ type Application interface {
 	// Set the module at the specified key, this should be safe across multiple threads
 	Set(Key, interface{}) error
 
+	// SetProvider registers a provider for the key, the value is built when it is first requested.
+	// Use the Provide function for a type-safe variant.
+	SetProvider(Key, Provider, Scope) error
+
+	// Inject fills the tagged fields of the struct the argument points to with values from the application
+	Inject(interface{}) error
+
 	// Logger gets the root logger for this application
 	Logger() logrus.FieldLogger
 
@@ -76,26 +85,86 @@ type Application interface {
 	// Info returns the app info object for this application
 	Info() cjm.AppInfo
 
+	// Profile returns the active config profile, it is empty when no profile is active
+	Profile() string
+
 	// Init the application and its modules with the config.
+	// When a module fails to initialize, the modules that were already initialized are stopped.
 	Init() error
 
+	// InitContext initializes the application like Init, giving up when the context is done
+	InitContext(context.Context) error
+
 	// Start the application an its enabled modules
+	// When a module fails to start, the modules that were already started are stopped.
 	Start() error
 
-	// Stop the application an its enabled modules
+	// StartContext starts the application like Start, giving up when the context is done
+	StartContext(context.Context) error
+
+	// Stop the application an its enabled modules, in the reverse order they were started.
+	// The sources of the config are no longer watched after the application stopped,
+	// Init or Start watches them again and reloads them to catch up with the changes that were missed.
 	Stop() error
+
+	// StopContext stops the application like Stop, giving up when the context is done
+	StopContext(context.Context) error
+
+	// Close stops the application and everything it runs in the background: the watches of the config,
+	// the signal handlers and the loggers it created. The output of the log package is restored.
+	// The application can't be used after it was closed.
+	Close() error
+
+	// Run the application: it initializes and starts the modules, then blocks until
+	// the process receives SIGINT or SIGTERM or a component reports a failure, and then it stops the modules.
+	// The result is an exit code for os.Exit.
+	Run() int
+
+	// RunContext runs the application like Run, it also shuts down when the context is done
+	RunContext(context.Context) int
+
+	// Fail reports an error from a component that runs in the background, like a http server.
+	// A running application shuts down when it receives a failure.
+	Fail(error)
+
+	// Loggers returns the registry with the named loggers of the application
+	Loggers() *logging.Registry
+
+	// Modules describes the modules of the application and their lifecycle state, in dependency order
+	Modules() []ModuleInfo
+
+	// Health runs the health and readiness checks of the modules
+	Health(context.Context) HealthReport
+
+	// Subscribe to lifecycle events, the returned function ends the subscription
+	Subscribe(EventHandler, ...EventKind) func()
+
+	// AddValidator adds a check a changed config needs to pass before it is applied
+	AddValidator(Validator)
+
+	// AddConfigSource adds a layer to the config, the settings of sources with a higher priority win.
+	// The source is loaded and applied right away, and watched for changes from then on.
+	// When it is added from a lifecycle phase, eg. from the Init of a module, the modules reload it once that phase is done,
+	// a failed reload is then only published as an event.
+	AddConfigSource(ConfigSource, Priority) error
+
+	// ConfigOrigin returns the name of the source the value of the key came from,
+	// it is empty when no source sets the key
+	ConfigOrigin(string) string
+
+	// IsSensitive is true when the value of the key, or a value in its subtree, contains a resolved secret.
+	// Those values are masked when the config is dumped or logged.
+	IsSensitive(string) bool
 }
 
-func addDefaultConfigPaths(v *viper.Viper, name string) {
-	v.SetConfigName("config")
+// defaultConfigPaths are the directories in CONFIG_PATH, or the default config directories for the name
+func defaultConfigPaths(name string) []string {
 	norm := strings.ToLower(name)
 	paths := filepath.Join(os.Getenv("HOME"), ".config", norm) + ":" + filepath.Join("/etc", norm) + ":etc:."
 	if os.Getenv("CONFIG_PATH") != "" {
 		paths = os.Getenv("CONFIG_PATH")
 	}
-	for _, path := range filepath.SplitList(paths) {
-		v.AddConfigPath(path)
-	}
+	return filepath.SplitList(paths)
 }
 
 var viperLock *sync.Mutex
@@ -104,17 +173,24 @@ func init() {
 	viperLock = new(sync.Mutex)
 }
 
-func createViper(name string, configPath string) (*viper.Viper, error) {
+func createViper(name string, o *options) (*viper.Viper, error) {
 	viperLock.Lock()
 	defer viperLock.Unlock()
 	v := viper.New()
-	if configPath == "" {
-		addDefaultConfigPaths(v, name)
+	if o.configFile == "" {
+		paths := o.configPaths
+		if paths == nil {
+			paths = defaultConfigPaths(name)
+		}
+		v.SetConfigName("config")
+		for _, path := range paths {
+			v.AddConfigPath(path)
+		}
 	} else {
-		if _, err := os.Stat(configPath); os.IsNotExist(err) {
-			return nil, fmt.Errorf("No config file found at %s", configPath)
+		if _, err := os.Stat(o.configFile); os.IsNotExist(err) {
+			return nil, fmt.Errorf("No config file found at %s", o.configFile)
 		}
-		dir, fname := filepath.Split(configPath)
+		dir, fname := filepath.Split(o.configFile)
 		// viper wants the file name without extention...
 		suffixes := []string{".json", ".yml", ".yaml", ".hcl", ".toml"}
 		for _, suffix := range suffixes {
@@ -127,8 +203,14 @@ func createViper(name string, configPath string) (*viper.Viper, error) {
 		v.AddConfigPath(dir)
 	}
 
-	if err := addViperRemoteConfig(v); err != nil {
-		return nil, err
+	if o.remoteURL != "" {
+		rp, err := parseRemoteURL(o.remoteURL, o.keyring)
+		if err != nil {
+			return nil, err
+		}
+		if err := addRemoteProvider(v, rp); err != nil {
+			return nil, err
+		}
 	}
 
 	if err := v.ReadInConfig(); err != nil {
@@ -136,193 +218,284 @@ func createViper(name string, configPath string) (*viper.Viper, error) {
 			return nil, err
 		}
 	}
-	v.SetEnvPrefix(name)
-	v.AutomaticEnv()
 
 	addViperDefaults(v)
 
 	return v, nil
 }
 
-func addViperRemoteConfig(v *viper.Viper) error {
-	// check if encryption is required CONFIG_KEYRING
-	keyring := os.Getenv("CONFIG_KEYRING")
-
-	// check for etcd env vars CONFIG_REMOTE_URL, eg:
-	// etcd://localhost:2379/[app-name]/config.[type]
-	// consul://localhost:8500/[app-name]/config.[type]
-	remURL := os.Getenv("CONFIG_REMOTE_URL")
-	if remURL == "" {
-		return nil
-	}
-	var provider, host, path, tpe string
-	u, err := url.Parse(remURL)
-	if err != nil {
-		return err
-	}
-	provider = strings.ToLower(u.Scheme)
-	host = u.Host
-	if provider == "etcd" {
-		host = "http://" + host
-	}
-	path = u.Path
-	tpe = strings.ToLower(strings.TrimLeft(filepath.Ext(path), "."))
-	if tpe == "" {
-		tpe = "json"
-	}
-
-	v.SetConfigType(tpe)
-	if keyring != "" {
-		if err := v.AddSecureRemoteProvider(provider, host, path, keyring); err != nil {
+func addRemoteProvider(v *viper.Viper, rp *remoteProvider) error {
+	v.SetConfigType(rp.tpe)
+	if rp.keyring != "" {
+		if err := v.AddSecureRemoteProvider(rp.provider, rp.endpoint, rp.path, rp.keyring); err != nil {
 			return err
 		}
 	} else {
 
-		if err := v.AddRemoteProvider(provider, host, path); err != nil {
+		if err := v.AddRemoteProvider(rp.provider, rp.endpoint, rp.path); err != nil {
 			return err
 		}
 	}
 
 	if err := v.ReadRemoteConfig(); err != nil {
-		return fmt.Errorf("config is invalid as %s", tpe)
+		return fmt.Errorf("config is invalid as %s", rp.tpe)
 	}
 
 	return nil
 }
 
+// defaultSources are the config file that was found, the config file of the profile, the remote config and the sources from the options
+func defaultSources(cfg *viper.Viper, profileFile string, o *options) ([]configLayer, error) {
+	var sources []configLayer
+	// files of a type viper doesn't support are ignored, like they are when the application is created
+	if file := cfg.ConfigFileUsed(); file != "" && supportedConfigType(file) {
+		src := FileSource(file)
+		sources = append(sources, configLayer{name: src.Name(), priority: PriorityFile, source: src})
+	}
+	if profileFile != "" {
+		src := FileSource(profileFile)
+		sources = append(sources, configLayer{name: src.Name(), priority: PriorityProfile, source: src})
+	}
+
+	if o.remoteURL != "" {
+		remote, err := parseRemoteURL(o.remoteURL, o.keyring)
+		if err != nil {
+			return nil, err
+		}
+		src := &remoteSource{remote: remote}
+		sources = append(sources, configLayer{name: src.Name(), priority: PriorityRemote, source: src})
+	}
+	sources = append(sources, o.sources...)
+
+	for i := range sources {
+		sources[i].id = i + 1
+	}
+	return sources, nil
+}
+
 func addViperDefaults(v *viper.Viper) {
 	v.SetDefault("tracer", map[interface{}]interface{}{"enable": true})
 	v.SetDefault("logging", map[interface{}]interface{}{"root": map[interface{}]interface{}{"level": "info"}})
 }
 
-func ensureDefaults(name string) (string, string, error) {
-	// configure version defaults
-	version := "dev"
-	if Version != "" {
-		version = Version
+func ensureName(name string) (string, error) {
+	if name != "" {
+		return name, nil
 	}
-
-	// configure name defaults
-	if name == "" {
-		name = os.Getenv("APP_NAME")
-		if name == "" {
-			exe, err := execName()
-			if err != nil {
-				return "", "", err
-			}
-			name = filepath.Base(exe)
-		}
+	if name = os.Getenv("APP_NAME"); name != "" {
+		return name, nil
 	}
-
-	return name, version, nil
+	exe, err := execName()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Base(exe), nil
 }
 
-func newWithCallback(nme string, configPath string, reload func(fsnotify.Event)) (Application, error) {
-	name, version, err := ensureDefaults(nme)
+// NewWithOptions creates an application with the specified name, configured with the options.
+// The options take precedence over the environment variables and the Version variable New uses.
+func NewWithOptions(nme string, opts ...Option) (Application, error) {
+	o := newOptions()
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	name, err := ensureName(nme)
+	if err != nil {
+		return nil, err
+	}
+	profile, err := activeProfile(o)
 	if err != nil {
 		return nil, err
 	}
 	appInfo := cjm.AppInfo{
 		Name:     name,
 		BasePath: "/",
-		Version:  version,
+		Version:  o.version,
 		Pid:      os.Getpid(),
 	}
 
-	cfg, err := createViper(name, configPath)
+	cfg, err := createViper(name, o)
 	if err != nil {
 		return nil, err
 	}
 
-	allLoggers := logging.NewRegistry(cfg, logrus.Fields{"app": appInfo.Name})
+	// from here on the config is composed from the layers of its sources,
+	// so it can be put back when a change is rejected
+	profileFile := profileConfigFile(name, profile, o)
+	sources, err := defaultSources(cfg, profileFile, o)
+	if err != nil {
+		return nil, err
+	}
+	layers, err := loadLayers(sources)
+	if err != nil {
+		return nil, err
+	}
+	sensitive, err := applyLayers(cfg, layers)
+	if err != nil {
+		return nil, err
+	}
 
-	log.SetOutput(allLoggers.Writer())
+	allLoggers := o.loggers
+	if allLoggers == nil {
+		allLoggers = logging.NewRegistry(cfg, logrus.Fields{"app": appInfo.Name})
+	} else {
+		allLoggers.Configure(cfg)
+	}
+
+	prevLogOutput := log.Writer()
+	logWriter := allLoggers.Writer()
+	log.SetOutput(logWriter)
 
 	tracer := allLoggers.Root().WithField("module", "trace")
 	trace := tracing.New("", tracer, nil)
 
-	go func() {
-		sigs := make(chan os.Signal, 1)
-		signal.Notify(sigs, syscall.SIGQUIT)
-		buf := make([]byte, 1<<20)
+	app := &defaultApplication{
+		appInfo:      appInfo,
+		profile:      profile,
+		allLoggers:   allLoggers,
+		rootTracer:   trace,
+		config:       cfg,
+		registry:     make(map[Key]interface{}, 100),
+		providers:    make(map[Key]*providerEntry, 20),
+		regLock:      new(sync.Mutex),
+		failures:     make(chan error, 1),
+		events:       newEventBus(),
+		layers:       layers,
+		sourceIDs:    len(sources),
+		changes:      make(chan configChange, 16),
+		watchSources: o.watchSources,
+		reloadHook:   o.reloadHook,
+		signals:      o.signals,
+		ownLoggers:   o.loggers == nil,
+		logWriter:    logWriter,
+		logOutput:    prevLogOutput,
+	}
 
-		for {
-			<-sigs
-			ln := goruntime.Stack(buf, true)
-			allLoggers.Root().Println(string(buf[:ln]))
-		}
-	}()
+	app.sensitive.Store(&sensitive)
+	if profile != "" && profileFile == "" {
+		app.Logger().Warnf("no config file found for profile %s", profile)
+	} else if profile != "" {
+		app.Logger().Infof("profile %s reads config from %s", profile, profileFile)
+	}
+	app.handleDiagnostics()
 
-	app := &defaultApplication{
-		appInfo:    appInfo,
-		allLoggers: allLoggers,
-		rootTracer: trace,
-		config:     cfg,
-		registry:   make(map[Key]interface{}, 100),
-		regLock:    new(sync.Mutex),
-	}
-	app.watchConfigurations(func(in fsnotify.Event) {
-		if reload != nil {
-			reload(in)
-		}
-		allLoggers.Reload()
-		for _, mod := range app.modules {
-			if err := mod.Reload(app); err != nil {
-				allLoggers.Root().Errorf("reload config: %v", err)
-			}
-		}
-		allLoggers.Root().Infoln("config file changed:", in.Name)
-	})
+	app.settings = settingsSnapshot(cfg)
+	app.watchConfigurations(nil)
 	return app, nil
 }
 
+func newWithCallback(nme string, configPath string, reload func(fsnotify.Event)) (Application, error) {
+	return NewWithOptions(nme, WithConfigFile(configPath), withReloadHook(reload))
+}
+
 // New application with the specified name, at the specified basepath
 func New(nme string) (Application, error) {
-	return newWithCallback(nme, "", nil)
+	return NewWithOptions(nme)
 }
 
 // NewWithConfig application with the specified name, with a specific config file path
 func NewWithConfig(nme string, configPath string) (Application, error) {
-	return newWithCallback(nme, configPath, nil)
+	return NewWithOptions(nme, WithConfigFile(configPath))
 }
 
 type defaultApplication struct {
 	appInfo    cjm.AppInfo
+	profile    string
 	allLoggers *logging.Registry
 	rootTracer tracing.Tracer
 	config     *viper.Viper
-	modules    []Module
-
-	registry map[Key]interface{}
-	regLock  *sync.Mutex
+	modules    []*registration
+	ordered    []*registration
+	active     []*registration
+	failures   chan error
+	events     *eventBus
+
+	// changeLock serializes config changes, it isn't held while the modules reload a change.
+	// reloadLock guards the state of the config and is never held while the application calls out,
+	// settings is the config as it was after the last change and layers are the documents it was read from.
+	changeLock sync.Mutex
+	reloadLock sync.Mutex
+	settings   map[string]interface{}
+	layers     configLayers
+	validators []Validator
+	bindings   []boundConfig
+	// sensitive are the keys of the values with a secret, they are read without taking the reload lock
+	sensitive atomic.Pointer[sensitiveKeys]
+
+	// the sources of the config are watched until watching is done, changes go through the debouncer.
+	// Watching is guarded by the reload lock, it stops with the application and starts again with Init or Start.
+	sourceIDs    int
+	changes      chan configChange
+	watching     context.Context
+	stopWatching context.CancelFunc
+	watchSources bool
+	reloadHook   func(fsnotify.Event)
+
+	// signals is false when the application doesn't handle signals,
+	// stopDiagnostics releases the diagnostic signals that were configured last
+	signals         bool
+	stopDiagnostics func()
+
+	// background tracks the goroutines that are waited for when the application is closed,
+	// the output of the log package is restored then and the loggers are closed when the application created them.
+	// A closed application doesn't watch its config again, closed is guarded by the reload lock.
+	background sync.WaitGroup
+	closeOnce  sync.Once
+	closed     bool
+	ownLoggers bool
+	logWriter  *io.PipeWriter
+	logOutput  io.Writer
+
+	// lifecycleLock guards the lifecycle state, phases can be triggered by config changes
+	lifecycleLock sync.Mutex
+	initialized   bool
+	started       bool
+
+	registry  map[Key]interface{}
+	providers map[Key]*providerEntry
+	regLock   *sync.Mutex
 }
 
-func (d *defaultApplication) watchConfigurations(reload func(fsnotify.Event)) {
-	viperLock.Lock()
-	defer viperLock.Unlock()
-	d.config.WatchConfig()
-	d.config.OnConfigChange(reload)
-
-	// we made it this far, it's clear the url means we're also connecting remotely
-	if os.Getenv("CONFIG_REMOTE_URL") != "" {
-		go func() {
-			for {
-				err := d.config.WatchRemoteConfig()
-				if err != nil {
-					d.Logger().Errorf("watching remote config: %v", err)
-					continue
-				}
-				reload(fsnotify.Event{Name: os.Getenv("CONFIG_REMOTE_URL"), Op: fsnotify.Write})
+func (d *defaultApplication) Add(modules ...Module) error {
+	for _, mod := range modules {
+		name, explicit := nameOf(mod)
+		if d.registered(name) {
+			if explicit {
+				return fmt.Errorf("a module named %s was already added", name)
+			}
+			// names derived from the package path are not unique, so number them
+			base := name
+			for i := 2; d.registered(name); i++ {
+				name = base + "-" + strconv.Itoa(i)
 			}
-		}()
+		}
+		d.modules = append(d.modules, newRegistration(name, mod))
 	}
+	d.ordered = nil
+	return nil
 }
 
-func (d *defaultApplication) Add(modules ...Module) error {
-	if len(modules) > 0 {
-		d.modules = append(d.modules, modules...)
+func (d *defaultApplication) registered(name string) bool {
+	for _, mod := range d.modules {
+		if mod.name == name {
+			return true
+		}
 	}
-	return nil
+	return false
+}
+
+// sortedModules returns the modules in dependency order
+func (d *defaultApplication) sortedModules() ([]*registration, error) {
+	if d.ordered != nil {
+		return d.ordered, nil
+	}
+	ordered, err := sortModules(d.modules, d.hasKey)
+	if err != nil {
+		return nil, err
+	}
+	d.ordered = ordered
+	return ordered, nil
 }
 
 // Get the module at the specified key, return nil when the component doesn't exist
@@ -332,13 +505,12 @@ func (d *defaultApplication) Get(key Key) interface{} {
 }
 
 // Get the module at the specified key, return false when the component doesn't exist
+// or when its provider fails to build it
 func (d *defaultApplication) GetOK(key Key) (interface{}, bool) {
-	d.regLock.Lock()
-	defer d.regLock.Unlock()
-
-	mod, ok := d.registry[key]
-	if !ok {
-		return nil, ok
+	mod, ok, err := d.resolve(key, nil)
+	if err != nil {
+		d.Logger().Errorf("resolving %q: %v", key, err)
+		return nil, false
 	}
 	return mod, ok
 }
@@ -366,33 +538,163 @@ func (d *defaultApplication) Config() *viper.Viper {
 	return d.config
 }
 
+func (d *defaultApplication) Loggers() *logging.Registry {
+	return d.allLoggers
+}
+
 func (d *defaultApplication) Info() cjm.AppInfo {
 	return d.appInfo
 }
 
+func (d *defaultApplication) Profile() string {
+	return d.profile
+}
+
 func (d *defaultApplication) Init() error {
-	for _, mod := range d.modules {
-		if err := mod.Init(d); err != nil {
-			return err
-		}
+	return d.InitContext(context.Background())
+}
+
+func (d *defaultApplication) InitContext(ctx context.Context) error {
+	d.lifecycleLock.Lock()
+	defer d.lifecycleLock.Unlock()
+
+	d.resumeWatching()
+	mods, err := d.sortedModules()
+	if err != nil {
+		return err
 	}
+	ctx, cancel := d.phaseContext(ctx, PhaseInit)
+	defer cancel()
+
+	done, errs := d.runModules(ctx, PhaseInit, mods)
+	for _, mod := range done {
+		d.activate(mod)
+	}
+	if len(errs) > 0 {
+		return d.rollback(done, errs)
+	}
+	d.initialized = true
 	return nil
 }
 
 func (d *defaultApplication) Start() error {
-	for _, mod := range d.modules {
-		if err := mod.Start(d); err != nil {
-			return err
-		}
+	return d.StartContext(context.Background())
+}
+
+func (d *defaultApplication) StartContext(ctx context.Context) error {
+	d.lifecycleLock.Lock()
+	defer d.lifecycleLock.Unlock()
+
+	d.resumeWatching()
+	mods, err := d.sortedModules()
+	if err != nil {
+		return err
+	}
+	ctx, cancel := d.phaseContext(ctx, PhaseStart)
+	defer cancel()
+
+	done, errs := d.runModules(ctx, PhaseStart, mods)
+	for _, mod := range done {
+		d.activate(mod)
 	}
+	if len(errs) > 0 {
+		return d.rollback(done, errs)
+	}
+	d.started = true
 	return nil
 }
 
 func (d *defaultApplication) Stop() error {
-	for _, mod := range d.modules {
-		if err := mod.Stop(d); err != nil {
-			return err
+	return d.StopContext(context.Background())
+}
+
+func (d *defaultApplication) StopContext(ctx context.Context) error {
+	d.lifecycleLock.Lock()
+	defer d.lifecycleLock.Unlock()
+
+	active := d.active
+	d.active = nil
+	d.initialized = false
+	d.started = false
+
+	// a stopped application doesn't follow the config until it is initialized or started again
+	d.pauseWatching()
+
+	ctx, cancel := d.phaseContext(ctx, PhaseStop)
+	defer cancel()
+	return joinErrors(d.stopAll(ctx, active))
+}
+
+func (d *defaultApplication) Close() error {
+	var errs []error
+	d.closeOnce.Do(func() {
+		d.reloadLock.Lock()
+		d.closed = true
+		d.reloadLock.Unlock()
+		errs = append(errs, d.Stop())
+
+		d.reloadLock.Lock()
+		if d.stopDiagnostics != nil {
+			d.stopDiagnostics()
+		}
+		d.reloadLock.Unlock()
+		d.background.Wait()
+
+		// another application might have taken over the log package since
+		if log.Writer() == io.Writer(d.logWriter) {
+			log.SetOutput(d.logOutput)
+		}
+		errs = append(errs, d.logWriter.Close())
+		if d.ownLoggers {
+			errs = append(errs, d.allLoggers.Close())
+		}
+	})
+	return joinErrors(errs)
+}
+
+// activate tracks a module that was initialized or started, so it gets stopped later
+func (d *defaultApplication) activate(mod *registration) {
+	for _, act := range d.active {
+		if act == mod {
+			return
 		}
 	}
-	return nil
+	d.active = append(d.active, mod)
+}
+
+// rollback stops the modules that succeeded before the causes happened,
+// the returned error contains the causes and the errors from stopping the modules.
+func (d *defaultApplication) rollback(done []*registration, causes []error) error {
+	var remaining []*registration
+	for _, act := range d.active {
+		if !containsModule(done, act) {
+			remaining = append(remaining, act)
+		}
+	}
+	d.active = remaining
+
+	// the context of the failed phase might be expired, stopping gets its own deadline
+	ctx, cancel := d.phaseContext(context.Background(), PhaseStop)
+	defer cancel()
+	return joinErrors(append(causes, d.stopAll(ctx, done)...))
+}
+
+// stopAll stops the modules in reverse order, errors don't prevent the remaining modules from being stopped
+func (d *defaultApplication) stopAll(ctx context.Context, mods []*registration) []error {
+	var errs []error
+	for i := len(mods) - 1; i >= 0; i-- {
+		if err := d.runPhase(ctx, PhaseStop, mods[i]); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
+
+func containsModule(mods []*registration, mod *registration) bool {
+	for _, m := range mods {
+		if m == mod {
+			return true
+		}
+	}
+	return false
 }
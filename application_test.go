@@ -3,8 +3,10 @@ package app
 import (
 	"errors"
 	"io/ioutil"
+	"log"
 	"os"
 	"path/filepath"
+	"runtime"
 	"testing"
 	"time"
 
@@ -38,7 +40,6 @@ count: 3
 
 func TestApplication_RemoteErrors(t *testing.T) {
 	defer os.Unsetenv("CONFIG_REMOTE_URL")
-	defer os.Unsetenv("CONFIG_KEYRING")
 
 	// invalid url
 	os.Setenv("CONFIG_REMOTE_URL", "etcd://[/")
@@ -46,14 +47,20 @@ func TestApplication_RemoteErrors(t *testing.T) {
 	assert.Error(t, err)
 
 	// invalid scheme
-	os.Setenv("CONFIG_REMOTE_URL", "zookeeper://127.0.0.1:2379/etcdenc/config.json")
-	assert.EqualError(t, addViperRemoteConfig(viper.New()), "Unsupported Remote Provider Type \"zookeeper\"")
-	os.Setenv("CONFIG_KEYRING", ".pubring.gpg")
-	assert.EqualError(t, addViperRemoteConfig(viper.New()), "Unsupported Remote Provider Type \"zookeeper\"")
+	assert.EqualError(t, addRemote(viper.New(), "zookeeper://127.0.0.1:2379/etcdenc/config.json", ""), "Unsupported Remote Provider Type \"zookeeper\"")
+	assert.EqualError(t, addRemote(viper.New(), "zookeeper://127.0.0.1:2379/etcdenc/config.json", ".pubring.gpg"), "Unsupported Remote Provider Type \"zookeeper\"")
 
 	// invalid type
-	os.Setenv("CONFIG_REMOTE_URL", "etcd://127.0.0.1:2379/etcdenc/config.unknown")
-	assert.EqualError(t, addViperRemoteConfig(viper.New()), "config is invalid as unknown")
+	assert.EqualError(t, addRemote(viper.New(), "etcd://127.0.0.1:2379/etcdenc/config.unknown", ""), "config is invalid as unknown")
+}
+
+// addRemote adds the remote config at the url to the viper config
+func addRemote(v *viper.Viper, remURL, keyring string) error {
+	rp, err := parseRemoteURL(remURL, keyring)
+	if err != nil {
+		return err
+	}
+	return addRemoteProvider(v, rp)
 }
 
 func encrypt(d []byte) ([]byte, error) {
@@ -70,11 +77,6 @@ func TestApplication_EtcdEncrypted(t *testing.T) {
 	if _, err := os.Stat(".secring.gpg"); os.IsNotExist(err) {
 		t.Skip("skipping, no keyring.")
 	}
-	defer os.Unsetenv("CONFIG_KEYRING")
-	defer os.Unsetenv("CONFIG_REMOTE_URL")
-	os.Setenv("CONFIG_KEYRING", ".secring.gpg")
-	os.Setenv("CONFIG_REMOTE_URL", "etcd://127.0.0.1:2379/etcdenc/config.json")
-
 	etcdc, err := etcd.New([]string{"http://127.0.0.1:2379"})
 	if assert.NoError(t, err) {
 		encrypted, err := encrypt([]byte(conjson))
@@ -85,7 +87,7 @@ func TestApplication_EtcdEncrypted(t *testing.T) {
 					assert.Equal(t, string(encrypted), string(b))
 
 					v := viper.New()
-					if assert.NoError(t, addViperRemoteConfig(v)) {
+					if assert.NoError(t, addRemote(v, "etcd://127.0.0.1:2379/etcdenc/config.json", ".secring.gpg")) {
 						assert.Equal(t, "go-app.test", v.GetString("name"))
 						assert.Equal(t, "a wonderful, magical place among the stars", v.Get("location"))
 						assert.Equal(t, 1, v.GetInt("count"))
@@ -97,9 +99,6 @@ func TestApplication_EtcdEncrypted(t *testing.T) {
 }
 
 func TestApplication_EtcdUnencrypted(t *testing.T) {
-	defer os.Unsetenv("CONFIG_REMOTE_URL")
-	os.Unsetenv("CONFIG_KEYRING")
-	os.Setenv("CONFIG_REMOTE_URL", "etcd://127.0.0.1:2379/etcdplain/config.json")
 
 	etcdc, err := etcd.New([]string{"http://127.0.0.1:2379"})
 	if assert.NoError(t, err) {
@@ -112,7 +111,7 @@ func TestApplication_EtcdUnencrypted(t *testing.T) {
 				assert.Equal(t, conjson, string(b))
 
 				v := viper.New()
-				if assert.NoError(t, addViperRemoteConfig(v)) {
+				if assert.NoError(t, addRemote(v, "etcd://127.0.0.1:2379/etcdplain/config.json", "")) {
 					assert.Equal(t, "go-app.test", v.GetString("name"))
 					assert.Equal(t, "a wonderful, magical place among the stars", v.Get("location"))
 					assert.Equal(t, 1, v.GetInt("count"))
@@ -120,14 +119,13 @@ func TestApplication_EtcdUnencrypted(t *testing.T) {
 			}
 		}
 
-		os.Setenv("CONFIG_REMOTE_URL", "etcd://127.0.0.1:2379/etcdplain/config")
 		if assert.NoError(t, etcdc.Set("/etcdplain/config", []byte(conjson))) {
 			b, err := etcdc.Get("/etcdplain/config")
 			if assert.NoError(t, err) {
 				assert.Equal(t, conjson, string(b))
 
 				v := viper.New()
-				if assert.NoError(t, addViperRemoteConfig(v)) {
+				if assert.NoError(t, addRemote(v, "etcd://127.0.0.1:2379/etcdplain/config", "")) {
 					assert.Equal(t, "go-app.test", v.GetString("name"))
 					assert.Equal(t, "a wonderful, magical place among the stars", v.Get("location"))
 					assert.Equal(t, 1, v.GetInt("count"))
@@ -255,11 +253,6 @@ func TestApplication_ConsulEncrypted(t *testing.T) {
 	if _, err := os.Stat(".secring.gpg"); os.IsNotExist(err) {
 		t.Skip("skipping, no keyring.")
 	}
-	defer os.Unsetenv("CONFIG_KEYRING")
-	defer os.Unsetenv("CONFIG_REMOTE_URL")
-	os.Setenv("CONFIG_KEYRING", ".secring.gpg")
-	os.Setenv("CONFIG_REMOTE_URL", "consul://127.0.0.1:8500/consulenc/config.json")
-
 	consulc, err := consul.New([]string{"127.0.0.1:8500"})
 	if assert.NoError(t, err) {
 		encrypted, err := encrypt([]byte(conjson))
@@ -270,7 +263,7 @@ func TestApplication_ConsulEncrypted(t *testing.T) {
 					assert.Equal(t, string(encrypted), string(b))
 
 					v := viper.New()
-					if assert.NoError(t, addViperRemoteConfig(v)) {
+					if assert.NoError(t, addRemote(v, "consul://127.0.0.1:8500/consulenc/config.json", ".secring.gpg")) {
 						assert.Equal(t, "go-app.test", v.GetString("name"))
 						assert.Equal(t, "a wonderful, magical place among the stars", v.Get("location"))
 						assert.Equal(t, 1, v.GetInt("count"))
@@ -283,9 +276,6 @@ func TestApplication_ConsulEncrypted(t *testing.T) {
 }
 
 func TestApplication_ConsulUnencrypted(t *testing.T) {
-	defer os.Unsetenv("CONFIG_REMOTE_URL")
-	os.Unsetenv("CONFIG_KEYRING")
-	os.Setenv("CONFIG_REMOTE_URL", "consul://127.0.0.1:8500/consulplain/config.json")
 
 	consulc, err := consul.New([]string{"127.0.0.1:8500"})
 	if assert.NoError(t, err) {
@@ -298,7 +288,7 @@ func TestApplication_ConsulUnencrypted(t *testing.T) {
 				assert.Equal(t, conjson, string(b))
 
 				v := viper.New()
-				if assert.NoError(t, addViperRemoteConfig(v)) {
+				if assert.NoError(t, addRemote(v, "consul://127.0.0.1:8500/consulplain/config.json", "")) {
 					assert.Equal(t, "go-app.test", v.GetString("name"))
 					assert.Equal(t, "a wonderful, magical place among the stars", v.Get("location"))
 					assert.Equal(t, 1, v.GetInt("count"))
@@ -432,7 +422,8 @@ func TestApplication_WatchFile(t *testing.T) {
 				}
 			}()
 			<-latch
-			assert.Equal(t, "other value", app.Config().GetString("name"))
+			// the module fails to reload the new config, so the previous config is put back
+			assert.Equal(t, "some value", app.Config().GetString("name"))
 		}
 	}
 }
@@ -453,7 +444,7 @@ func TestApplication_EnvConfigPath(t *testing.T) {
 			fpath := filepath.Join(cpath, "config.json")
 			content := []byte(`{"name":"some-config"}`)
 			if assert.NoError(t, ioutil.WriteFile(fpath, content, 0644)) {
-				v, err := createViper("test3", "")
+				v, err := createViper("test3", newOptions())
 				if assert.NoError(t, err) {
 					assert.Equal(t, "some-config", v.GetString("name"))
 				}
@@ -466,7 +457,7 @@ func TestApplication_EnvConfigPath(t *testing.T) {
 			fpath := filepath.Join(tpar, "config.json")
 			content := []byte(`{"name":"other-config"}`)
 			if assert.NoError(t, ioutil.WriteFile(fpath, content, 0644)) {
-				v, err := createViper("test4", "")
+				v, err := createViper("test4", newOptions())
 				if assert.NoError(t, err) {
 					assert.Equal(t, "other-config", v.GetString("name"))
 				}
@@ -668,3 +659,45 @@ func (f someModule) Start(_ Application) error {
 func (f someModule) Stop(_ Application) error {
 	return nil
 }
+
+func TestApplication_Close(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-app")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+	fpath := filepath.Join(dir, "config.json")
+	if !assert.NoError(t, ioutil.WriteFile(fpath, []byte(`{"name": "close"}`), 0644)) {
+		return
+	}
+
+	// the first application starts the goroutine of the signal package, which runs until the process exits
+	warmup, err := NewWithOptions("warmup", WithWatchers(false))
+	if assert.NoError(t, err) {
+		assert.NoError(t, warmup.Close())
+	}
+
+	logOutput := log.Writer()
+	goroutines := runtime.NumGoroutine()
+
+	app, err := NewWithOptions("close", WithConfigFile(fpath))
+	if assert.NoError(t, err) {
+		assert.NotEqual(t, logOutput, log.Writer())
+		assert.NoError(t, app.Add(MakeModule(Name("closed"))))
+		assert.NoError(t, app.Init())
+		assert.NoError(t, app.Start())
+
+		assert.NoError(t, app.Close())
+		assert.Equal(t, StateStopped, app.Modules()[0].State)
+		assert.Equal(t, logOutput, log.Writer())
+		// closing twice is harmless
+		assert.NoError(t, app.Close())
+
+		// the pipe of the log package and the file watcher shut down asynchronously
+		deadline := time.Now().Add(2 * time.Second)
+		for runtime.NumGoroutine() > goroutines && time.Now().Before(deadline) {
+			time.Sleep(10 * time.Millisecond)
+		}
+		assert.LessOrEqual(t, runtime.NumGoroutine(), goroutines)
+	}
+}
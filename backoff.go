@@ -0,0 +1,71 @@
+package app
+
+import (
+	"math/rand"
+	"time"
+)
+
+const (
+	// DefaultWatchBackoff is how long a failed watch of a config source waits before it is retried the first time
+	DefaultWatchBackoff = 500 * time.Millisecond
+	// DefaultMaxWatchBackoff is the longest wait between retries of a failed watch, when it isn't configured
+	DefaultMaxWatchBackoff = 30 * time.Second
+)
+
+// backoff doubles the wait after every attempt up to the max, the wait is jittered between half and the full delay
+// so applications that lost the same remote config don't retry in lockstep.
+type backoff struct {
+	initial time.Duration
+	max     time.Duration
+	delay   time.Duration
+}
+
+func newBackoff(initial, max time.Duration) *backoff {
+	if initial <= 0 {
+		initial = DefaultWatchBackoff
+	}
+	if max < initial {
+		max = initial
+	}
+	return &backoff{initial: initial, max: max}
+}
+
+// next returns the wait before the next attempt
+func (b *backoff) next() time.Duration {
+	switch {
+	case b.delay == 0:
+		b.delay = b.initial
+	case b.delay < b.max:
+		b.delay *= 2
+		if b.delay > b.max {
+			b.delay = b.max
+		}
+	}
+	half := b.delay / 2
+	return half + time.Duration(rand.Int63n(int64(b.delay-half)+1))
+}
+
+// reset starts over with the initial wait
+func (b *backoff) reset() {
+	b.delay = 0
+}
+
+// watchBackoff is the backoff for retrying the watch of a config source, configured as
+//
+//	config:
+//	  backoff:
+//	    initial: 1s
+//	    max: 1m
+func (d *defaultApplication) watchBackoff() *backoff {
+	d.reloadLock.Lock()
+	defer d.reloadLock.Unlock()
+
+	initial, max := DefaultWatchBackoff, DefaultMaxWatchBackoff
+	if d.config.IsSet("config.backoff.initial") {
+		initial = d.config.GetDuration("config.backoff.initial")
+	}
+	if d.config.IsSet("config.backoff.max") {
+		max = d.config.GetDuration("config.backoff.max")
+	}
+	return newBackoff(initial, max)
+}
@@ -0,0 +1,184 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/casualjim/go-app/logging"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBackoff_Next(t *testing.T) {
+	b := newBackoff(100*time.Millisecond, time.Second)
+	for _, delay := range []time.Duration{100, 200, 400, 800, 1000, 1000} {
+		wait := b.next()
+		assert.True(t, wait >= delay*time.Millisecond/2 && wait <= delay*time.Millisecond, "%v should be between %v and %v", wait, delay*time.Millisecond/2, delay*time.Millisecond)
+	}
+
+	b = newBackoff(0, 0)
+	assert.Equal(t, DefaultWatchBackoff, b.initial)
+	assert.Equal(t, DefaultWatchBackoff, b.max)
+}
+
+// flakySource fails to watch once, and fails to load while it is unavailable
+type flakySource struct {
+	lock    sync.Mutex
+	loads   int
+	watches int
+	stopped chan struct{}
+}
+
+func (f *flakySource) Name() string { return "flaky" }
+
+func (f *flakySource) Load() (map[string]interface{}, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.loads++
+	switch {
+	case f.loads == 1:
+		return map[string]interface{}{"name": "first"}, nil
+	case f.loads <= 4:
+		return nil, errors.New("connection refused")
+	}
+	return map[string]interface{}{"name": "recovered"}, nil
+}
+
+func (f *flakySource) Watch(ctx context.Context, _ func()) error {
+	f.lock.Lock()
+	f.watches++
+	first := f.watches == 1
+	f.lock.Unlock()
+	if first {
+		return errors.New("connection refused")
+	}
+	<-ctx.Done()
+	close(f.stopped)
+	return nil
+}
+
+// syncBuffer collects the log output of a test
+type syncBuffer struct {
+	lock sync.Mutex
+	buf  bytes.Buffer
+}
+
+func (s *syncBuffer) Write(p []byte) (int, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.buf.Write(p)
+}
+
+func (s *syncBuffer) String() string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.buf.String()
+}
+
+func TestBackoff_WatchSource(t *testing.T) {
+	out := new(syncBuffer)
+	logging.RegisterWriter("backoff-test", func(_ *viper.Viper) io.Writer { return out })
+	settings := EmbeddedSource("settings.yaml", []byte(`
+config:
+  debounce: 10ms
+  backoff:
+    initial: 10ms
+    max: 20ms
+logging:
+  root:
+    level: debug
+    writer: backoff-test
+`))
+
+	app, err := NewWithOptions("backoff", WithConfigSource(settings, PriorityDefaults), WithSignalHandling(false))
+	if !assert.NoError(t, err) {
+		return
+	}
+	rec := new(eventRecorder)
+	app.Subscribe(rec.handle, EventConfigChanged)
+
+	src := &flakySource{stopped: make(chan struct{})}
+	if assert.NoError(t, app.AddConfigSource(src, PriorityRemote)) {
+		// the source is reloaded once it is available again
+		assert.Eventually(t, func() bool { return len(rec.recorded()) == 2 }, 2*time.Second, 10*time.Millisecond)
+		assert.Equal(t, "recovered", app.Config().GetString("name"))
+
+		logs := out.String()
+		assert.Equal(t, 1, strings.Count(logs, "config from flaky is unavailable"))
+		assert.Equal(t, 3, strings.Count(logs, "config from flaky is still unavailable"))
+		assert.Equal(t, 1, strings.Count(logs, "config from flaky is available again"))
+
+		assert.NoError(t, app.Stop())
+		select {
+		case <-src.stopped:
+		case <-time.After(time.Second):
+			t.Fatal("the watch didn't stop when the application stopped")
+		}
+	}
+}
+
+// brokenWatchSource loads fine, but its watch fails right away every time
+type brokenWatchSource struct {
+	lock    sync.Mutex
+	watches int
+}
+
+func (b *brokenWatchSource) Name() string { return "broken" }
+
+func (b *brokenWatchSource) Load() (map[string]interface{}, error) {
+	return map[string]interface{}{"name": "broken"}, nil
+}
+
+func (b *brokenWatchSource) Watch(_ context.Context, _ func()) error {
+	b.lock.Lock()
+	b.watches++
+	b.lock.Unlock()
+	return errors.New("watch not supported")
+}
+
+func (b *brokenWatchSource) Watches() int {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.watches
+}
+
+func TestBackoff_WatchKeepsFailing(t *testing.T) {
+	out := new(syncBuffer)
+	logging.RegisterWriter("backoff-failing-test", func(_ *viper.Viper) io.Writer { return out })
+	settings := EmbeddedSource("settings.yaml", []byte(`
+config:
+  debounce: 10ms
+  backoff:
+    initial: 10ms
+    max: 40ms
+logging:
+  root:
+    level: debug
+    writer: backoff-failing-test
+`))
+
+	app, err := NewWithOptions("backoff", WithConfigSource(settings, PriorityDefaults), WithSignalHandling(false))
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer app.Stop()
+
+	src := new(brokenWatchSource)
+	if assert.NoError(t, app.AddConfigSource(src, PriorityRemote)) {
+		time.Sleep(400 * time.Millisecond)
+
+		// the wait keeps growing up to the max instead of starting over after every failed watch
+		assert.True(t, src.Watches() < 20, "watched %d times", src.Watches())
+
+		logs := out.String()
+		assert.Equal(t, 1, strings.Count(logs, "config from broken is unavailable"))
+		assert.Equal(t, 1, strings.Count(logs, "config from broken is available again"))
+		assert.True(t, strings.Count(logs, "watch of config from broken failed again") > 1)
+	}
+}
@@ -0,0 +1,270 @@
+package app
+
+import (
+	"encoding"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// Binding holds the value of a config subtree decoded into a struct.
+// The value is replaced as a whole after every config change that is applied,
+// so readers never observe a partially updated config.
+type Binding[T any] struct {
+	key   string
+	base  T
+	value atomic.Pointer[T]
+}
+
+// Bind decodes the config subtree at the key into a copy of the target and keeps it up to date.
+// Fields that are zero in the target and absent from the config get the value of their default struct tag.
+// The subtree is decoded with the mapstructure tags, the same way viper unmarshals.
+//
+// A config change that fails to decode into the struct is rejected like an invalid config.
+func Bind[T any](a Application, key string, target *T) (*Binding[T], error) {
+	if target == nil {
+		return nil, fmt.Errorf("bind %s: target is nil", key)
+	}
+	if reflect.TypeOf(target).Elem().Kind() != reflect.Struct {
+		return nil, fmt.Errorf("bind %s: target must point to a struct, got %T", key, target)
+	}
+	binder, ok := a.(interface{ addBinding(boundConfig) error })
+	if !ok {
+		return nil, fmt.Errorf("bind %s: application %T doesn't support bindings", key, a)
+	}
+
+	b := &Binding[T]{key: key, base: *target}
+	if err := binder.addBinding(b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// Key of the config subtree that is bound
+func (b *Binding[T]) Key() string {
+	return b.key
+}
+
+// Load returns the current value, it must be treated as read-only
+func (b *Binding[T]) Load() *T {
+	return b.value.Load()
+}
+
+func (b *Binding[T]) decode(cfg *viper.Viper) (interface{}, error) {
+	// the maps and slices of the target are copied, decoding into them would change the values that were loaded before
+	var value T
+	reflect.ValueOf(&value).Elem().Set(deepCopy(reflect.ValueOf(b.base)))
+	if err := applyDefaults(reflect.ValueOf(&value).Elem()); err != nil {
+		return nil, fmt.Errorf("bind %s: %w", b.key, err)
+	}
+	if cfg.IsSet(b.key) {
+		if err := cfg.UnmarshalKey(b.key, &value); err != nil {
+			return nil, fmt.Errorf("bind %s: %w", b.key, err)
+		}
+	}
+	return &value, nil
+}
+
+func (b *Binding[T]) swap(value interface{}) interface{} {
+	return b.value.Swap(value.(*T))
+}
+
+// boundConfig is the part of a binding that is independent of its type
+type boundConfig interface {
+	decode(*viper.Viper) (interface{}, error)
+	swap(interface{}) interface{}
+}
+
+// addBinding decodes the current config into the binding, it waits for a change that is being applied
+// so the binding can't miss it
+func (d *defaultApplication) addBinding(b boundConfig) error {
+	d.changeLock.Lock()
+	defer d.changeLock.Unlock()
+	d.reloadLock.Lock()
+	defer d.reloadLock.Unlock()
+
+	value, err := b.decode(d.config)
+	if err != nil {
+		return err
+	}
+	b.swap(value)
+	d.bindings = append(d.bindings, b)
+	return nil
+}
+
+// decodeBindings decodes the config for every binding without storing the values, all errors are returned
+func (d *defaultApplication) decodeBindings(cfg *viper.Viper) ([]interface{}, error) {
+	values := make([]interface{}, len(d.bindings))
+	var errs []error
+	for i, b := range d.bindings {
+		value, err := b.decode(cfg)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		values[i] = value
+	}
+	return values, joinErrors(errs)
+}
+
+// swapBindings stores the values and returns the values they replaced
+func (d *defaultApplication) swapBindings(values []interface{}) []interface{} {
+	prev := make([]interface{}, len(values))
+	for i, value := range values {
+		prev[i] = d.bindings[i].swap(value)
+	}
+	return prev
+}
+
+// deepCopy returns a copy of the value that shares no pointers, maps or slices with it.
+// Unexported fields are copied as they are, they aren't decoded into.
+func deepCopy(v reflect.Value) reflect.Value {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return v
+		}
+		cp := reflect.New(v.Type().Elem())
+		cp.Elem().Set(deepCopy(v.Elem()))
+		return cp
+	case reflect.Interface:
+		if v.IsNil() {
+			return v
+		}
+		cp := reflect.New(v.Type()).Elem()
+		cp.Set(deepCopy(v.Elem()))
+		return cp
+	case reflect.Map:
+		if v.IsNil() {
+			return v
+		}
+		cp := reflect.MakeMapWithSize(v.Type(), v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			cp.SetMapIndex(iter.Key(), deepCopy(iter.Value()))
+		}
+		return cp
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+		cp := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			cp.Index(i).Set(deepCopy(v.Index(i)))
+		}
+		return cp
+	case reflect.Array:
+		cp := reflect.New(v.Type()).Elem()
+		for i := 0; i < v.Len(); i++ {
+			cp.Index(i).Set(deepCopy(v.Index(i)))
+		}
+		return cp
+	case reflect.Struct:
+		cp := reflect.New(v.Type()).Elem()
+		cp.Set(v)
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).PkgPath == "" {
+				cp.Field(i).Set(deepCopy(v.Field(i)))
+			}
+		}
+		return cp
+	default:
+		return v
+	}
+}
+
+var (
+	durationType        = reflect.TypeOf(time.Duration(0))
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// applyDefaults sets the default tag of every zero field, nested structs are walked as well
+func applyDefaults(v reflect.Value) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+		fv := v.Field(i)
+		if def, ok := field.Tag.Lookup("default"); ok {
+			if !fv.IsZero() {
+				continue
+			}
+			if err := setDefault(fv, def); err != nil {
+				return fmt.Errorf("default for %s: %w", field.Name, err)
+			}
+			continue
+		}
+		if fv.Kind() == reflect.Struct {
+			if err := applyDefaults(fv); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func setDefault(v reflect.Value, def string) error {
+	if v.CanAddr() && v.Addr().Type().Implements(textUnmarshalerType) {
+		return v.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(def))
+	}
+	if v.Type() == durationType {
+		d, err := time.ParseDuration(def)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(def)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(def)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(def, 0, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(def, 0, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(def, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	case reflect.Slice:
+		// slices are comma separated, like viper decodes strings into slices
+		if def == "" {
+			return nil
+		}
+		parts := strings.Split(def, ",")
+		slice := reflect.MakeSlice(v.Type(), len(parts), len(parts))
+		for i, part := range parts {
+			if err := setDefault(slice.Index(i), strings.TrimSpace(part)); err != nil {
+				return err
+			}
+		}
+		v.Set(slice)
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+	return nil
+}
@@ -0,0 +1,184 @@
+package app
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+type ordersConfig struct {
+	DB      string        `mapstructure:"db" default:"postgres://localhost/orders"`
+	Port    int           `mapstructure:"port" default:"8080"`
+	Timeout time.Duration `mapstructure:"timeout" default:"5s"`
+	Tags    []string      `mapstructure:"tags" default:"a, b"`
+	Pool    struct {
+		Size int `mapstructure:"size" default:"10"`
+	} `mapstructure:"pool"`
+}
+
+func TestBind_Defaults(t *testing.T) {
+	app, err := New("")
+	if assert.NoError(t, err) {
+		b, err := Bind(app, "orders", &ordersConfig{Port: 9090})
+		if assert.NoError(t, err) {
+			cfg := b.Load()
+			assert.Equal(t, "postgres://localhost/orders", cfg.DB)
+			// values in the target win over the defaults
+			assert.Equal(t, 9090, cfg.Port)
+			assert.Equal(t, 5*time.Second, cfg.Timeout)
+			assert.Equal(t, []string{"a", "b"}, cfg.Tags)
+			assert.Equal(t, 10, cfg.Pool.Size)
+			assert.Equal(t, "orders", b.Key())
+		}
+
+		_, err = Bind(app, "orders", (*ordersConfig)(nil))
+		assert.EqualError(t, err, "bind orders: target is nil")
+		_, err = Bind(app, "orders", new(string))
+		assert.EqualError(t, err, "bind orders: target must point to a struct, got *string")
+		_, err = Bind(app, "orders", &struct {
+			Ch chan int `default:"1"`
+		}{})
+		assert.EqualError(t, err, "bind orders: default for Ch: unsupported type chan int")
+	}
+}
+
+func TestBind_Reload(t *testing.T) {
+	appi, err := New("")
+	if assert.NoError(t, err) {
+		app := appi.(*defaultApplication)
+		rec := new(eventRecorder)
+		app.Subscribe(rec.handle, EventConfigRejected, EventConfigRolledBack)
+
+		b, err := Bind(app, "orders", &ordersConfig{})
+		if !assert.NoError(t, err) {
+			return
+		}
+		var seen []string
+		app.Add(MakeModule(Name("orders"), Reload(func(_ Application) error {
+			db := b.Load().DB
+			seen = append(seen, db)
+			if db == "broken" {
+				return errors.New("can't connect")
+			}
+			return nil
+		})))
+
+		app.configChanged(fsnotify.Event{Name: "config.yaml"}, fileLayers(map[string]interface{}{
+			"orders": map[string]interface{}{"db": "mysql", "pool": map[string]interface{}{"size": 20}},
+		}), nil)
+		first := b.Load()
+		assert.Equal(t, "mysql", first.DB)
+		assert.Equal(t, 20, first.Pool.Size)
+		assert.Equal(t, 8080, first.Port)
+
+		// a config that doesn't decode is rejected
+		app.configChanged(fsnotify.Event{Name: "config.yaml"}, fileLayers(map[string]interface{}{
+			"orders": map[string]interface{}{"db": "mysql", "port": "not a number"},
+		}), nil)
+		assert.Same(t, first, b.Load())
+
+		// a failed reload puts the previous value back
+		app.configChanged(fsnotify.Event{Name: "config.yaml"}, fileLayers(map[string]interface{}{
+			"orders": map[string]interface{}{"db": "broken"},
+		}), nil)
+		assert.Equal(t, "mysql", b.Load().DB)
+		assert.Equal(t, 20, b.Load().Pool.Size)
+		assert.Equal(t, []string{"mysql", "broken", "mysql"}, seen)
+
+		events := rec.recorded()
+		if assert.Len(t, events, 2) {
+			assert.Equal(t, EventConfigRejected, events[0].Kind)
+			assert.Contains(t, events[0].Err.Error(), "bind orders:")
+			assert.Equal(t, EventConfigRolledBack, events[1].Kind)
+		}
+	}
+}
+
+func TestBind_KeepsLoadedValues(t *testing.T) {
+	appi, err := New("")
+	if !assert.NoError(t, err) {
+		return
+	}
+	app := appi.(*defaultApplication)
+	app.AddValidator(Validate(func(cfg *viper.Viper) error {
+		if cfg.GetString("cluster.labels.env") == "invalid" {
+			return errors.New("invalid env")
+		}
+		return nil
+	}))
+	type clusterConfig struct {
+		Labels map[string]string `mapstructure:"labels"`
+		Hosts  []string          `mapstructure:"hosts"`
+		Leader *struct {
+			Host string `mapstructure:"host"`
+		} `mapstructure:"leader"`
+	}
+	target := &clusterConfig{Labels: map[string]string{"team": "orders"}, Hosts: []string{""}}
+	b, err := Bind(app, "cluster", target)
+	if !assert.NoError(t, err) {
+		return
+	}
+	cluster := func(env, host string) configLayers {
+		return fileLayers(map[string]interface{}{"cluster": map[string]interface{}{
+			"labels": map[string]interface{}{"env": env},
+			"hosts":  []interface{}{host},
+			"leader": map[string]interface{}{"host": host},
+		}})
+	}
+
+	assert.NoError(t, app.configChanged(fsnotify.Event{Name: "config.yaml"}, cluster("one", "h1"), nil))
+	first := b.Load()
+	assert.Equal(t, map[string]string{"team": "orders", "env": "one"}, first.Labels)
+	assert.Equal(t, []string{"h1"}, first.Hosts)
+	assert.Equal(t, "h1", first.Leader.Host)
+
+	// the value that was loaded before doesn't change with the config
+	assert.NoError(t, app.configChanged(fsnotify.Event{Name: "config.yaml"}, cluster("two", "h2"), nil))
+	assert.Error(t, app.configChanged(fsnotify.Event{Name: "config.yaml"}, cluster("invalid", "h3"), nil))
+	assert.Equal(t, map[string]string{"team": "orders", "env": "one"}, first.Labels)
+	assert.Equal(t, []string{"h1"}, first.Hosts)
+	assert.Equal(t, "h1", first.Leader.Host)
+
+	second := b.Load()
+	assert.Equal(t, map[string]string{"team": "orders", "env": "two"}, second.Labels)
+	assert.Equal(t, []string{"h2"}, second.Hosts)
+	assert.Equal(t, "h2", second.Leader.Host)
+	assert.Equal(t, map[string]string{"team": "orders"}, target.Labels)
+	assert.Equal(t, []string{""}, target.Hosts)
+}
+
+func TestBind_FromLifecycle(t *testing.T) {
+	appi, err := New("")
+	if !assert.NoError(t, err) {
+		return
+	}
+	app := appi.(*defaultApplication)
+	var fromInit, fromReload *Binding[ordersConfig]
+	app.Add(MakeModule(Name("orders"),
+		Init(func(a Application) error {
+			var err error
+			fromInit, err = Bind(a, "orders", &ordersConfig{})
+			return err
+		}),
+		Reload(func(a Application) error {
+			var err error
+			fromReload, err = Bind(a, "orders", &ordersConfig{})
+			return err
+		}),
+	))
+
+	if finishes(t, func() { err = app.Init() }) && assert.NoError(t, err) {
+		change := fileLayers(map[string]interface{}{"orders": map[string]interface{}{"port": 9090}})
+		if finishes(t, func() { err = app.configChanged(fsnotify.Event{Name: "config.yaml"}, change, nil) }) {
+			assert.NoError(t, err)
+			assert.Equal(t, 9090, fromInit.Load().Port)
+			if assert.NotNil(t, fromReload) {
+				assert.Equal(t, 9090, fromReload.Load().Port)
+			}
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package app
+
+import (
+	"context"
+	"sort"
+)
+
+// concurrency is the maximum number of modules that run a phase at the same time, configured as
+//
+//	lifecycle:
+//	  concurrency: 4
+//
+// Modules run one after the other when it isn't configured, 0 or less removes the limit.
+func (d *defaultApplication) concurrency(mods int) int {
+	if !d.config.IsSet("lifecycle.concurrency") {
+		return 1
+	}
+	limit := d.config.GetInt("lifecycle.concurrency")
+	if limit <= 0 || limit > mods {
+		return mods
+	}
+	return limit
+}
+
+// runModules runs the phase for the enabled modules, which are in dependency order.
+// A module runs as soon as the modules that provide the keys it requires have completed,
+// but no more modules run at the same time than the configured concurrency.
+// After a module fails no new modules are started, the modules that are already running are waited for.
+//
+// It returns the modules that completed, in dependency order, and the errors of the modules that failed.
+func (d *defaultApplication) runModules(ctx context.Context, phase Phase, mods []*registration) ([]*registration, []error) {
+	var enabled []*registration
+	for _, mod := range mods {
+		if d.checkEnabled(mod) {
+			enabled = append(enabled, mod)
+		}
+	}
+
+	if d.concurrency(len(enabled)) <= 1 {
+		var done []*registration
+		for _, mod := range enabled {
+			if err := d.runPhase(ctx, phase, mod); err != nil {
+				return done, []error{err}
+			}
+			d.logCompleted(phase, mod)
+			done = append(done, mod)
+		}
+		return done, nil
+	}
+	return d.runConcurrently(ctx, phase, enabled)
+}
+
+func (d *defaultApplication) runConcurrently(ctx context.Context, phase Phase, mods []*registration) ([]*registration, []error) {
+	type result struct {
+		index int
+		err   error
+	}
+
+	deps := dependencies(mods)
+	waiting := make([]int, len(mods))
+	dependents := make([][]int, len(mods))
+	var ready []int
+	for i, ds := range deps {
+		waiting[i] = len(ds)
+		for _, j := range ds {
+			dependents[j] = append(dependents[j], i)
+		}
+		if len(ds) == 0 {
+			ready = append(ready, i)
+		}
+	}
+
+	limit := d.concurrency(len(mods))
+	results := make(chan result, len(mods))
+	finished := make([]bool, len(mods))
+	errs := make([]error, len(mods))
+	running, logged, failed := 0, 0, false
+
+	for {
+		// always start the module that was added first, this keeps the order deterministic with a limit of 1
+		sort.Ints(ready)
+		for !failed && running < limit && len(ready) > 0 {
+			next := ready[0]
+			ready = ready[1:]
+			running++
+			go func(i int) {
+				results <- result{index: i, err: d.runPhase(ctx, phase, mods[i])}
+			}(next)
+		}
+		if running == 0 {
+			break
+		}
+
+		res := <-results
+		running--
+		finished[res.index] = true
+		if res.err != nil {
+			errs[res.index] = res.err
+			failed = true
+			continue
+		}
+		for _, dep := range dependents[res.index] {
+			waiting[dep]--
+			if waiting[dep] == 0 {
+				ready = append(ready, dep)
+			}
+		}
+
+		// only log the modules that completed before every module that comes earlier in the order,
+		// so the log reads the same no matter which module happens to be faster
+		for logged < len(mods) && finished[logged] && errs[logged] == nil {
+			d.logCompleted(phase, mods[logged])
+			logged++
+		}
+	}
+
+	var done []*registration
+	var failures []error
+	for i, mod := range mods {
+		switch {
+		case errs[i] != nil:
+			failures = append(failures, errs[i])
+		case finished[i]:
+			if i >= logged {
+				d.logCompleted(phase, mod)
+			}
+			done = append(done, mod)
+		}
+	}
+	return done, failures
+}
+
+func (d *defaultApplication) logCompleted(phase Phase, mod *registration) {
+	d.Logger().Debugf("%s %s completed in %v", phase, mod.name, mod.took(phase))
+}
@@ -0,0 +1,131 @@
+package app
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConcurrency_Independent(t *testing.T) {
+	app, err := New("")
+	if assert.NoError(t, err) {
+		app.Config().Set("lifecycle.concurrency", 0)
+
+		// both modules wait for each other, this only completes when they start at the same time
+		var wg sync.WaitGroup
+		wg.Add(2)
+		waitForOther := func(_ Application) error {
+			wg.Done()
+			done := make(chan struct{})
+			go func() { wg.Wait(); close(done) }()
+			select {
+			case <-done:
+				return nil
+			case <-time.After(2 * time.Second):
+				return errors.New("modules did not start concurrently")
+			}
+		}
+		app.Add(
+			MakeModule(Name("a"), Start(waitForOther)),
+			MakeModule(Name("b"), Start(waitForOther)),
+		)
+
+		assert.NoError(t, app.Init())
+		assert.NoError(t, app.Start())
+	}
+}
+
+func TestConcurrency_Dependencies(t *testing.T) {
+	app, err := New("")
+	if assert.NoError(t, err) {
+		app.Config().Set("lifecycle.concurrency", 0)
+
+		var lock sync.Mutex
+		var order []string
+		record := func(name string, delay time.Duration) LifecycleCallback {
+			return Init(func(_ Application) error {
+				time.Sleep(delay)
+				lock.Lock()
+				order = append(order, name)
+				lock.Unlock()
+				return nil
+			})
+		}
+		var stopped []string
+		stop := func(name string) LifecycleCallback {
+			return Stop(func(_ Application) error {
+				stopped = append(stopped, name)
+				return nil
+			})
+		}
+		app.Add(
+			MakeModule(Name("orders"), Requires("ordersDb"), record("orders", 0), stop("orders")),
+			MakeModule(Name("db"), Provides("ordersDb"), record("db", 50*time.Millisecond), stop("db")),
+			MakeModule(Name("standalone"), record("standalone", 0), stop("standalone")),
+		)
+
+		if assert.NoError(t, app.Init()) {
+			assert.Equal(t, []string{"standalone", "db", "orders"}, order)
+			assert.NoError(t, app.Stop())
+			assert.Equal(t, []string{"standalone", "orders", "db"}, stopped)
+		}
+	}
+}
+
+func TestConcurrency_Limit(t *testing.T) {
+	app, err := New("")
+	if assert.NoError(t, err) {
+		app.Config().Set("lifecycle.concurrency", 2)
+
+		var running, most int32
+		var lock sync.Mutex
+		track := Init(func(_ Application) error {
+			cur := atomic.AddInt32(&running, 1)
+			lock.Lock()
+			if cur > most {
+				most = cur
+			}
+			lock.Unlock()
+			time.Sleep(20 * time.Millisecond)
+			atomic.AddInt32(&running, -1)
+			return nil
+		})
+		for _, name := range []string{"a", "b", "c", "d", "e"} {
+			app.Add(MakeModule(Name(name), track))
+		}
+
+		assert.NoError(t, app.Init())
+		assert.Equal(t, int32(2), most)
+	}
+}
+
+func TestConcurrency_Failures(t *testing.T) {
+	app, err := New("")
+	if assert.NoError(t, err) {
+		app.Config().Set("lifecycle.concurrency", 0)
+
+		var stopped int32
+		stop := Stop(func(_ Application) error {
+			atomic.AddInt32(&stopped, 1)
+			return nil
+		})
+		app.Add(
+			MakeModule(Name("ok"), stop),
+			MakeModule(Name("a"), stop, Start(func(_ Application) error { return errors.New("no port") })),
+			MakeModule(Name("b"), stop, Start(func(_ Application) error { return errors.New("no disk") })),
+		)
+
+		assert.NoError(t, app.Init())
+		err := app.Start()
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), "start a: no port")
+			assert.Contains(t, err.Error(), "start b: no disk")
+		}
+		// only the module that started is rolled back
+		assert.Equal(t, int32(1), atomic.LoadInt32(&stopped))
+	}
+}
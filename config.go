@@ -0,0 +1,332 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/spf13/viper"
+)
+
+// ConfigWatcher is implemented by modules that only need to reload when part of the config changes.
+// Watches returns the keys of the subtrees the module depends on, eg. orders.db
+// Modules that don't implement it are reloaded on every change.
+type ConfigWatcher interface {
+	Watches() []string
+}
+
+// Watches declares the config subtrees a module made with MakeModule reloads for
+func Watches(keys ...string) LifecycleCallback {
+	return watches(keys)
+}
+
+type watches []string
+
+// Call implements the callback interface, a declaration has no behavior
+func (watches) Call(_ Application) error {
+	return nil
+}
+
+type changedKeysKey struct{}
+
+// ChangedKeys returns the config keys that changed, from the context of a reload.
+// The keys are sorted and use the dotted notation of viper, eg. orders.db.url
+func ChangedKeys(ctx context.Context) []string {
+	keys, _ := ctx.Value(changedKeysKey{}).([]string)
+	return keys
+}
+
+func withChangedKeys(ctx context.Context, keys []string) context.Context {
+	return context.WithValue(ctx, changedKeysKey{}, keys)
+}
+
+// settingsSnapshot copies the value of every key in the config
+func settingsSnapshot(cfg *viper.Viper) map[string]interface{} {
+	keys := cfg.AllKeys()
+	settings := make(map[string]interface{}, len(keys))
+	for _, key := range keys {
+		settings[key] = cfg.Get(key)
+	}
+	return settings
+}
+
+// diffSettings returns the sorted keys that were added, removed or got a different value
+func diffSettings(prev, next map[string]interface{}) []string {
+	var changed []string
+	for key, value := range next {
+		if old, ok := prev[key]; !ok || !reflect.DeepEqual(old, value) {
+			changed = append(changed, key)
+		}
+	}
+	for key := range prev {
+		if _, ok := next[key]; !ok {
+			changed = append(changed, key)
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
+
+// inSubtree is true when the key is the root of the subtree or one of its descendants
+func inSubtree(key, root string) bool {
+	root = strings.ToLower(root)
+	return key == root || strings.HasPrefix(key, root+".")
+}
+
+// Validator checks a config before it is applied. Modules that implement it validate the config
+// when they are enabled in it, other validators are added with AddValidator.
+type Validator interface {
+	Validate(*viper.Viper) error
+}
+
+// Validate declares a validation of the config for a module made with MakeModule,
+// it can also be passed to AddValidator.
+type Validate func(*viper.Viper) error
+
+// Call implements the callback interface, validations don't run as part of the lifecycle
+func (Validate) Call(_ Application) error {
+	return nil
+}
+
+// Validate implements the validator interface
+func (fn Validate) Validate(cfg *viper.Viper) error {
+	return fn(cfg)
+}
+
+func (d *defaultApplication) AddValidator(validator Validator) {
+	d.reloadLock.Lock()
+	d.validators = append(d.validators, validator)
+	d.reloadLock.Unlock()
+}
+
+// validate the config with the validators and the modules that are enabled in it, all errors are returned.
+// No lock is held while the validators run, so they can use the application.
+func (d *defaultApplication) validate(cfg *viper.Viper) error {
+	d.reloadLock.Lock()
+	validators := d.validators
+	d.reloadLock.Unlock()
+
+	var errs []error
+	for _, validator := range validators {
+		if err := validator.Validate(cfg); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	// like Modules, this reads the modules without the lifecycle lock, a module can change the config from its Init
+	for _, mod := range d.modules {
+		validator, ok := mod.module.(Validator)
+		if !ok || !enabledIn(cfg, mod) {
+			continue
+		}
+		if err := validator.Validate(cfg); err != nil {
+			errs = append(errs, fmt.Errorf("module %s: %w", mod.name, err))
+		}
+	}
+	return joinErrors(errs)
+}
+
+// configChanged applies the new layers of the config, see updateLayers
+func (d *defaultApplication) configChanged(in fsnotify.Event, next configLayers, hook func(fsnotify.Event)) error {
+	return d.updateLayers(in, func(configLayers) configLayers { return next }, hook)
+}
+
+// configUpdate is a change of the config that was applied, the modules reload it once it is applied
+type configUpdate struct {
+	in      fsnotify.Event
+	prev    configLayers
+	next    configLayers
+	changed []string
+}
+
+// updateLayers applies the layers the update returns for the current layers. The config is validated and decoded
+// into the bindings before the loggers and the modules that watch the changed keys are reloaded.
+// An invalid config is rejected, and when a module fails to reload the change is undone and the modules reload again.
+// Nothing is reloaded when no setting changed. The error is the reason the change was rejected or rolled back.
+func (d *defaultApplication) updateLayers(in fsnotify.Event, update func(configLayers) configLayers, hook func(fsnotify.Event)) error {
+	if hook != nil {
+		// the hook observes the outcome of the change
+		defer hook(in)
+	}
+
+	change, err := d.applyUpdate(in, update)
+	if err != nil || change == nil {
+		return err
+	}
+	return d.reloadUpdate(change)
+}
+
+// applyUpdate applies the layers the update returns, when they are valid. Changes are applied one at a time,
+// the lock is released before the modules reload so they can use the config and its sources from their lifecycle.
+// The validators and the bindings get a candidate config, the config of the application only changes once they accept it.
+// It returns nil when no setting changed.
+func (d *defaultApplication) applyUpdate(in fsnotify.Event, update func(configLayers) configLayers) (*configUpdate, error) {
+	d.changeLock.Lock()
+	defer d.changeLock.Unlock()
+
+	d.reloadLock.Lock()
+	prev := d.layers
+	next := update(prev)
+	d.reloadLock.Unlock()
+
+	candidate, err := newCandidateConfig(next)
+	if err != nil {
+		d.events.publish(Event{Kind: EventConfigRejected, Source: in.Name, Err: err})
+		d.Logger().Errorf("config from %s can't be applied, keeping the previous config: %v", in.Name, err)
+		return nil, err
+	}
+	// the settings only change with the change lock held
+	changed := diffSettings(d.settings, candidate.settings)
+	if len(changed) == 0 {
+		d.reloadLock.Lock()
+		d.layers = next
+		d.sensitive.Store(&candidate.sensitive)
+		d.reloadLock.Unlock()
+		d.Logger().Debugln("config changed, but no setting changed:", in.Name)
+		return nil, nil
+	}
+
+	verr := d.validate(candidate.config)
+	// the bindings only change with the change lock held
+	values, berr := d.decodeBindings(candidate.config)
+	if err := joinErrors([]error{verr, berr}); err != nil {
+		d.events.publish(Event{Kind: EventConfigRejected, Source: in.Name, Keys: changed, Err: err})
+		d.Logger().Errorf("config from %s is invalid, keeping the previous config: %v", in.Name, err)
+		return nil, err
+	}
+
+	d.reloadLock.Lock()
+	err = d.commitConfig(candidate, next)
+	if err == nil {
+		d.swapBindings(values)
+	}
+	d.reloadLock.Unlock()
+	if err != nil {
+		d.events.publish(Event{Kind: EventConfigRejected, Source: in.Name, Keys: changed, Err: err})
+		d.Logger().Errorf("config from %s can't be applied, keeping the previous config: %v", in.Name, err)
+		return nil, err
+	}
+
+	d.allLoggers.Configure(d.config)
+	return &configUpdate{in: in, prev: prev, next: next, changed: changed}, nil
+}
+
+// reloadUpdate reloads the modules that watch the changed keys, the change is undone when one of them fails.
+// While a lifecycle phase runs, eg. when a module changed the config from its Init, the modules reload
+// in the background once that phase is done. The outcome is then only published as an event.
+func (d *defaultApplication) reloadUpdate(change *configUpdate) error {
+	if !d.lifecycleLock.TryLock() {
+		d.background.Add(1)
+		go func() {
+			defer d.background.Done()
+			d.lifecycleLock.Lock()
+			defer d.lifecycleLock.Unlock()
+			d.reloadUpdateLocked(change)
+		}()
+		return nil
+	}
+	defer d.lifecycleLock.Unlock()
+	return d.reloadUpdateLocked(change)
+}
+
+func (d *defaultApplication) reloadUpdateLocked(change *configUpdate) error {
+	err := d.reloadModulesLocked(change.changed)
+	if err == nil {
+		d.reloadDiagnostics(change.changed)
+		d.events.publish(Event{Kind: EventConfigChanged, Source: change.in.Name, Keys: change.changed})
+		d.Logger().Infoln("config changed:", change.in.Name)
+		return nil
+	}
+
+	d.rollbackUpdate(change)
+	d.events.publish(Event{Kind: EventConfigRolledBack, Source: change.in.Name, Keys: change.changed, Err: err})
+	d.Logger().Errorf("config from %s failed to reload, rolled back to the previous config: %v", change.in.Name, err)
+	return err
+}
+
+// rollbackUpdate undoes the change and reloads the modules, the caller holds the lifecycle lock.
+// Layers that changed again since are kept, so a later change isn't lost.
+func (d *defaultApplication) rollbackUpdate(change *configUpdate) {
+	d.changeLock.Lock()
+	d.reloadLock.Lock()
+	layers := d.layers.reverted(change.prev, change.next)
+	d.reloadLock.Unlock()
+
+	candidate, err := newCandidateConfig(layers)
+	if err != nil {
+		d.changeLock.Unlock()
+		d.Logger().Errorf("restoring the previous config: %v", err)
+		return
+	}
+	values, berr := d.decodeBindings(candidate.config)
+
+	d.reloadLock.Lock()
+	changed := diffSettings(d.settings, candidate.settings)
+	if err := d.commitConfig(candidate, layers); err != nil {
+		d.reloadLock.Unlock()
+		d.changeLock.Unlock()
+		d.Logger().Errorf("restoring the previous config: %v", err)
+		return
+	}
+	if berr != nil {
+		d.Logger().Errorf("binding the previous config: %v", berr)
+	} else {
+		d.swapBindings(values)
+	}
+	d.reloadLock.Unlock()
+	d.allLoggers.Configure(d.config)
+	d.changeLock.Unlock()
+
+	if err := d.reloadModulesLocked(changed); err != nil {
+		d.Logger().Errorf("reloading the previous config: %v", err)
+	}
+	d.reloadDiagnostics(changed)
+}
+
+// reloadDiagnostics subscribes to the diagnostic signals again, when their config changed
+func (d *defaultApplication) reloadDiagnostics(changed []string) {
+	for _, key := range changed {
+		if inSubtree(key, "diagnostics") {
+			d.reloadLock.Lock()
+			d.handleDiagnostics()
+			d.reloadLock.Unlock()
+			return
+		}
+	}
+}
+
+// candidateConfig is the config of a change, read into a viper of its own so it can be checked
+// without changing the config of the application
+type candidateConfig struct {
+	config    *viper.Viper
+	data      []byte
+	sensitive sensitiveKeys
+	settings  map[string]interface{}
+}
+
+func newCandidateConfig(layers configLayers) (*candidateConfig, error) {
+	data, sensitive, err := renderLayers(layers)
+	if err != nil {
+		return nil, err
+	}
+	cfg := viper.New()
+	addViperDefaults(cfg)
+	if err := readLayers(cfg, data); err != nil {
+		return nil, err
+	}
+	return &candidateConfig{config: cfg, data: data, sensitive: sensitive, settings: settingsSnapshot(cfg)}, nil
+}
+
+// commitConfig reads the candidate into the config of the application, the caller holds the reload lock
+func (d *defaultApplication) commitConfig(candidate *candidateConfig, layers configLayers) error {
+	if err := readLayers(d.config, candidate.data); err != nil {
+		return err
+	}
+	d.layers = layers
+	d.settings = candidate.settings
+	d.sensitive.Store(&candidate.sensitive)
+	return nil
+}
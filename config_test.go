@@ -0,0 +1,329 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfig_DiffSettings(t *testing.T) {
+	prev := map[string]interface{}{"name": "orders", "db.url": "a", "db.pool": 1, "tags": []interface{}{"x"}}
+	next := map[string]interface{}{"name": "orders", "db.url": "b", "tags": []interface{}{"x", "y"}, "cache.ttl": "1m"}
+	assert.Equal(t, []string{"cache.ttl", "db.pool", "db.url", "tags"}, diffSettings(prev, next))
+	assert.Empty(t, diffSettings(next, next))
+}
+
+func TestConfig_ReloadWatchedSubtrees(t *testing.T) {
+	appi, err := New("")
+	if assert.NoError(t, err) {
+		app := appi.(*defaultApplication)
+
+		reloads := make(map[string][]string)
+		reloader := func(name string) LifecycleCallback {
+			return ReloadContext(func(ctx context.Context, _ Application) error {
+				reloads[name] = ChangedKeys(ctx)
+				return nil
+			})
+		}
+		app.Add(
+			MakeModule(Name("orders"), Watches("orders.db"), reloader("orders")),
+			MakeModule(Name("payments"), Watches("payments"), reloader("payments")),
+			MakeModule(Name("everything"), reloader("everything")),
+		)
+
+		orders := map[string]interface{}{"db": map[string]interface{}{"url": "postgres://localhost"}, "name": "orders"}
+		app.configChanged(fsnotify.Event{Name: "config.yaml"}, fileLayers(map[string]interface{}{"orders": orders}), nil)
+		assert.Equal(t, map[string][]string{
+			"orders":     {"orders.db.url", "orders.name"},
+			"everything": {"orders.db.url", "orders.name"},
+		}, reloads)
+
+		// nothing changed, so nothing reloads
+		reloads = make(map[string][]string)
+		app.configChanged(fsnotify.Event{Name: "config.yaml"}, app.layers, nil)
+		assert.Empty(t, reloads)
+
+		settings := map[string]interface{}{"orders": orders, "payments": map[string]interface{}{"provider": "stripe"}}
+		app.configChanged(fsnotify.Event{Name: "config.yaml"}, fileLayers(settings), nil)
+		assert.Equal(t, map[string][]string{
+			"payments":   {"payments.provider"},
+			"everything": {"payments.provider"},
+		}, reloads)
+	}
+}
+
+func TestConfig_ChangedEvent(t *testing.T) {
+	appi, err := New("")
+	if assert.NoError(t, err) {
+		app := appi.(*defaultApplication)
+		rec := new(eventRecorder)
+		app.Subscribe(rec.handle, EventConfigChanged)
+
+		settings := map[string]interface{}{"orders": map[string]interface{}{"db": map[string]interface{}{"url": "postgres://localhost"}}}
+		app.configChanged(fsnotify.Event{Name: "config.yaml"}, fileLayers(settings), nil)
+		events := rec.recorded()
+		if assert.Len(t, events, 1) {
+			assert.Equal(t, "config.yaml", events[0].Source)
+			assert.Equal(t, []string{"orders.db.url"}, events[0].Keys)
+		}
+	}
+}
+
+func TestConfig_Rejected(t *testing.T) {
+	appi, err := New("")
+	if assert.NoError(t, err) {
+		app := appi.(*defaultApplication)
+		rec := new(eventRecorder)
+		app.Subscribe(rec.handle, EventConfigChanged, EventConfigRejected)
+
+		var reloads int
+		app.AddValidator(Validate(func(cfg *viper.Viper) error {
+			if cfg.GetInt("orders.port") <= 0 {
+				return errors.New("orders.port must be positive")
+			}
+			return nil
+		}))
+		app.Add(MakeModule(
+			Name("orders"),
+			Validate(func(cfg *viper.Viper) error {
+				if cfg.GetString("orders.db") == "" {
+					return errors.New("orders.db is required")
+				}
+				return nil
+			}),
+			Reload(func(_ Application) error { reloads++; return nil }),
+		))
+
+		good := fileLayers(map[string]interface{}{"orders": map[string]interface{}{"port": 8080, "db": "postgres"}})
+		app.configChanged(fsnotify.Event{Name: "config.yaml"}, good, nil)
+		assert.Equal(t, 8080, app.Config().GetInt("orders.port"))
+		assert.Equal(t, 1, reloads)
+
+		bad := fileLayers(map[string]interface{}{"orders": map[string]interface{}{"port": -1}})
+		app.configChanged(fsnotify.Event{Name: "config.yaml"}, bad, nil)
+		assert.Equal(t, 8080, app.Config().GetInt("orders.port"))
+		assert.Equal(t, "postgres", app.Config().GetString("orders.db"))
+		assert.Equal(t, 1, reloads)
+
+		events := rec.recorded()
+		if assert.Len(t, events, 2) {
+			assert.Equal(t, EventConfigChanged, events[0].Kind)
+			assert.Equal(t, EventConfigRejected, events[1].Kind)
+			assert.Equal(t, []string{"orders.db", "orders.port"}, events[1].Keys)
+			assert.EqualError(t, events[1].Err, "orders.port must be positive; module orders: orders.db is required")
+		}
+
+		// a disabled module doesn't validate the config
+		disabled := fileLayers(map[string]interface{}{
+			"orders":  map[string]interface{}{"port": 9090},
+			"modules": map[string]interface{}{"orders": map[string]interface{}{"enabled": false}},
+		})
+		app.configChanged(fsnotify.Event{Name: "config.yaml"}, disabled, nil)
+		assert.Equal(t, 9090, app.Config().GetInt("orders.port"))
+	}
+}
+
+func TestConfig_RolledBack(t *testing.T) {
+	appi, err := New("")
+	if assert.NoError(t, err) {
+		app := appi.(*defaultApplication)
+		rec := new(eventRecorder)
+		app.Subscribe(rec.handle, EventConfigChanged, EventConfigRolledBack)
+
+		var seen []string
+		app.Add(MakeModule(Name("orders"), Reload(func(a Application) error {
+			url := a.Config().GetString("orders.db")
+			seen = append(seen, url)
+			if url == "broken" {
+				return errors.New("can't connect")
+			}
+			return nil
+		})))
+
+		app.configChanged(fsnotify.Event{Name: "config.yaml"}, fileLayers(map[string]interface{}{"orders": map[string]interface{}{"db": "postgres"}}), nil)
+		app.configChanged(fsnotify.Event{Name: "config.yaml"}, fileLayers(map[string]interface{}{"orders": map[string]interface{}{"db": "broken"}}), nil)
+
+		assert.Equal(t, "postgres", app.Config().GetString("orders.db"))
+		assert.Equal(t, []string{"postgres", "broken", "postgres"}, seen)
+		events := rec.recorded()
+		if assert.Len(t, events, 2) {
+			assert.Equal(t, EventConfigRolledBack, events[1].Kind)
+			assert.EqualError(t, events[1].Err, "reload orders: can't connect")
+		}
+
+		// the rolled back config is the baseline for the next change
+		app.configChanged(fsnotify.Event{Name: "config.yaml"}, fileLayers(map[string]interface{}{"orders": map[string]interface{}{"db": "mysql"}}), nil)
+		assert.Equal(t, "mysql", app.Config().GetString("orders.db"))
+	}
+}
+
+// fileLayers is a config that is read from a single file
+func TestConfig_RejectedNotVisible(t *testing.T) {
+	appi, err := New("")
+	if !assert.NoError(t, err) {
+		return
+	}
+	app := appi.(*defaultApplication)
+	app.configChanged(fsnotify.Event{Name: "config.yaml"}, fileLayers(map[string]interface{}{"name": "orders"}), nil)
+
+	var seen, candidate string
+	app.AddValidator(Validate(func(cfg *viper.Viper) error {
+		seen, candidate = app.Config().GetString("name"), cfg.GetString("name")
+		if candidate == "typo" {
+			return errors.New("unknown name")
+		}
+		return nil
+	}))
+	assert.Error(t, app.configChanged(fsnotify.Event{Name: "config.yaml"}, fileLayers(map[string]interface{}{"name": "typo"}), nil))
+	// the application keeps its config while the change is validated
+	assert.Equal(t, "orders", seen)
+	assert.Equal(t, "typo", candidate)
+	assert.Equal(t, "orders", app.Config().GetString("name"))
+
+	assert.NoError(t, app.configChanged(fsnotify.Event{Name: "config.yaml"}, fileLayers(map[string]interface{}{"name": "payments"}), nil))
+	assert.Equal(t, "orders", seen)
+	assert.Equal(t, "payments", app.Config().GetString("name"))
+}
+
+func fileLayers(settings map[string]interface{}) configLayers {
+	return configLayers{{id: 1, name: "config.yaml", priority: PriorityFile, settings: settings}}
+}
+
+func TestConfig_MergeLayers(t *testing.T) {
+	layers := configLayers{}.
+		with(configLayer{id: 1, name: "remote", priority: PriorityRemote, settings: map[string]interface{}{"name": "remote", "db": map[string]interface{}{"url": "remote"}}}).
+		with(configLayer{id: 2, name: "file", priority: PriorityFile, settings: map[string]interface{}{"name": "file", "db": map[interface{}]interface{}{"url": "file", "pool": 5}}})
+	assert.Equal(t, map[string]interface{}{
+		"name": "remote",
+		"db":   map[string]interface{}{"url": "remote", "pool": 5},
+	}, layers.merged())
+
+	origin, ok := layers.origin("DB.Pool")
+	assert.True(t, ok)
+	assert.Equal(t, "file", origin)
+	origin, _ = layers.origin("db.url")
+	assert.Equal(t, "remote", origin)
+	_, ok = layers.origin("db.url.host")
+	assert.False(t, ok)
+}
+
+// finishes fails the test when fn doesn't return in time, eg. because it deadlocked
+func finishes(t *testing.T, fn func()) bool {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		fn()
+	}()
+	select {
+	case <-done:
+		return true
+	case <-time.After(2 * time.Second):
+		t.Error("deadlocked")
+		return false
+	}
+}
+
+func TestConfig_ReloadAddsValidator(t *testing.T) {
+	appi, err := New("")
+	if !assert.NoError(t, err) {
+		return
+	}
+	app := appi.(*defaultApplication)
+	var validated int32
+	app.Add(MakeModule(Name("orders"), Reload(func(a Application) error {
+		a.AddValidator(Validate(func(*viper.Viper) error {
+			atomic.AddInt32(&validated, 1)
+			return nil
+		}))
+		return nil
+	})))
+
+	change := fileLayers(map[string]interface{}{"name": "first"})
+	if finishes(t, func() { err = app.configChanged(fsnotify.Event{Name: "config.yaml"}, change, nil) }) {
+		assert.NoError(t, err)
+		assert.NoError(t, app.configChanged(fsnotify.Event{Name: "config.yaml"}, fileLayers(map[string]interface{}{"name": "second"}), nil))
+		assert.Equal(t, int32(1), atomic.LoadInt32(&validated))
+	}
+}
+
+func TestConfig_ChangeDuringInit(t *testing.T) {
+	app, err := NewWithOptions("locks", WithSignalHandling(false), WithWatchers(false))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	// the validator of a concurrent change waits for the module to add a validator from its init
+	validating, added := make(chan struct{}), make(chan struct{})
+	var once sync.Once
+	app.AddValidator(Validate(func(cfg *viper.Viper) error {
+		if cfg.GetBool("concurrent") {
+			once.Do(func() { close(validating) })
+			select {
+			case <-added:
+			case <-time.After(time.Second):
+			}
+		}
+		return nil
+	}))
+
+	var reloads int32
+	app.Add(MakeModule(Name("orders"), Watches("concurrent"),
+		Init(func(a Application) error {
+			a.AddValidator(Validate(func(*viper.Viper) error { return nil }))
+			close(added)
+			return nil
+		}),
+		Reload(func(Application) error {
+			atomic.AddInt32(&reloads, 1)
+			return nil
+		}),
+	))
+
+	concurrent := make(chan error, 1)
+	go func() {
+		concurrent <- app.AddConfigSource(newMemorySource("concurrent", map[string]interface{}{"concurrent": true}), PriorityRemote)
+	}()
+	// viper isn't safe for concurrent use, so init starts once the concurrent change was written
+	select {
+	case <-validating:
+	case <-time.After(2 * time.Second):
+		t.Fatal("the concurrent change wasn't validated")
+	}
+	if finishes(t, func() { err = app.Init() }) {
+		assert.NoError(t, err)
+		assert.NoError(t, <-concurrent)
+		// the module reloads the concurrent change once the init phase is done
+		assert.Eventually(t, func() bool { return atomic.LoadInt32(&reloads) == 1 }, 2*time.Second, 10*time.Millisecond)
+		assert.NoError(t, app.Close())
+	}
+}
+
+func TestConfig_RevertedLayers(t *testing.T) {
+	file := configLayer{id: 1, name: "config.yaml", priority: PriorityFile, settings: map[string]interface{}{"name": "first"}}
+	env := configLayer{id: 2, name: "env", priority: PriorityEnv, settings: map[string]interface{}{"port": 8080}}
+	prev := configLayers{file, env}
+
+	changedFile := file
+	changedFile.settings = map[string]interface{}{"name": "second"}
+	remote := configLayer{id: 3, name: "remote", priority: PriorityRemote, settings: map[string]interface{}{"name": "remote"}}
+	next := prev.replaced(map[int]map[string]interface{}{1: changedFile.settings}).with(remote)
+
+	// the env changed after the change that is reverted
+	current := next.replaced(map[int]map[string]interface{}{2: {"port": 9090}})
+
+	reverted := current.reverted(prev, next)
+	if assert.Len(t, reverted, 2) {
+		assert.Equal(t, map[string]interface{}{"name": "first"}, reverted[0].settings)
+		assert.Equal(t, map[string]interface{}{"port": 9090}, reverted[1].settings)
+	}
+
+	// a layer the change removed is added again
+	assert.Equal(t, prev, configLayers{file}.reverted(prev, configLayers{file}))
+}
@@ -0,0 +1,153 @@
+package app
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// sortModules orders the modules so that every module comes after the modules that provide the keys it requires.
+// Modules that don't depend on each other keep the order in which they were added.
+// The known function reports keys that are already present in the application and need no provider.
+func sortModules(mods []*registration, known func(Key) bool) ([]*registration, error) {
+	providers := make(map[Key]int, len(mods))
+	for i, mod := range mods {
+		for _, key := range mod.provides() {
+			if j, ok := providers[key]; ok && j != i {
+				return nil, fmt.Errorf("key %q is provided by both module %s and module %s", key, mods[j].name, mod.name)
+			}
+			providers[key] = i
+		}
+	}
+
+	// edges point from a provider to the modules that require one of its keys
+	edges := make([][]int, len(mods))
+	inDegree := make([]int, len(mods))
+	var missing []string
+	for i, mod := range mods {
+		seen := make(map[int]struct{}, len(mod.requires()))
+		for _, key := range mod.requires() {
+			j, ok := providers[key]
+			if !ok {
+				if known == nil || !known(key) {
+					missing = append(missing, fmt.Sprintf("module %s requires %q", mod.name, key))
+				}
+				continue
+			}
+			if _, dup := seen[j]; dup || j == i {
+				continue
+			}
+			seen[j] = struct{}{}
+			edges[j] = append(edges[j], i)
+			inDegree[i]++
+		}
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("no module provides the required keys: %s", strings.Join(missing, ", "))
+	}
+
+	var ready []int
+	for i := range mods {
+		if inDegree[i] == 0 {
+			ready = append(ready, i)
+		}
+	}
+
+	result := make([]*registration, 0, len(mods))
+	for len(ready) > 0 {
+		// always take the module that was added first, this keeps the order deterministic
+		sort.Ints(ready)
+		next := ready[0]
+		ready = ready[1:]
+		result = append(result, mods[next])
+
+		for _, dep := range edges[next] {
+			inDegree[dep]--
+			if inDegree[dep] == 0 {
+				ready = append(ready, dep)
+			}
+		}
+	}
+
+	if len(result) < len(mods) {
+		return nil, fmt.Errorf("dependency cycle between modules: %s", describeCycle(mods, edges, inDegree))
+	}
+	return result, nil
+}
+
+// describeCycle walks from a module that is still waiting to one of its providers that is still waiting,
+// until it reaches a module it visited before. The path from that module back to itself is the cycle,
+// it reads as: a requires b requires a.
+func describeCycle(mods []*registration, edges [][]int, inDegree []int) string {
+	providers := make([][]int, len(mods))
+	start := -1
+	for from, tos := range edges {
+		if inDegree[from] == 0 {
+			continue
+		}
+		for _, to := range tos {
+			if inDegree[to] > 0 {
+				providers[to] = append(providers[to], from)
+				if start < 0 {
+					start = to
+				}
+			}
+		}
+	}
+
+	var path []int
+	visited := make(map[int]int, len(mods))
+	for cur := start; cur >= 0; {
+		if at, ok := visited[cur]; ok {
+			// start the cycle at the module that was added first
+			cycle := path[at:]
+			first := 0
+			for i := range cycle {
+				if cycle[i] < cycle[first] {
+					first = i
+				}
+			}
+			var names []string
+			for i := range cycle {
+				names = append(names, mods[cycle[(first+i)%len(cycle)]].name)
+			}
+			names = append(names, mods[cycle[first]].name)
+			return strings.Join(names, " -> ")
+		}
+		visited[cur] = len(path)
+		path = append(path, cur)
+		if len(providers[cur]) == 0 {
+			break
+		}
+		cur = providers[cur][0]
+	}
+	return "unknown"
+}
+
+// dependencies returns for every module the indexes of the modules that provide one of the keys it requires.
+// Keys that none of the modules provide are ignored, those were checked when the modules were sorted.
+func dependencies(mods []*registration) [][]int {
+	providers := make(map[Key]int, len(mods))
+	for i, mod := range mods {
+		for _, key := range mod.provides() {
+			providers[key] = i
+		}
+	}
+
+	deps := make([][]int, len(mods))
+	for i, mod := range mods {
+		seen := make(map[int]struct{}, len(mod.requires()))
+		for _, key := range mod.requires() {
+			j, ok := providers[key]
+			if !ok || j == i {
+				continue
+			}
+			if _, dup := seen[j]; dup {
+				continue
+			}
+			seen[j] = struct{}{}
+			deps[i] = append(deps[i], j)
+		}
+	}
+	return deps
+}
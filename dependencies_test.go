@@ -0,0 +1,132 @@
+package app
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func recordingModule(name string, order *[]string, prov []Key, req []Key) Module {
+	return MakeModule(
+		Name(name),
+		Provides(prov...),
+		Requires(req...),
+		Init(func(app Application) error {
+			*order = append(*order, name)
+			for _, k := range prov {
+				app.Set(k, name)
+			}
+			return nil
+		}),
+	)
+}
+
+func TestDependencies_InitOrder(t *testing.T) {
+	var order []string
+	app, err := New("")
+	if assert.NoError(t, err) {
+		app.Add(
+			recordingModule("orders", &order, []Key{"ordersService"}, []Key{"ordersDb", "payments"}),
+			recordingModule("standalone", &order, nil, nil),
+			recordingModule("payments", &order, []Key{"payments"}, []Key{"ordersDb"}),
+			recordingModule("db", &order, []Key{"ordersDb"}, nil),
+		)
+
+		if assert.NoError(t, app.Init()) {
+			assert.Equal(t, []string{"standalone", "db", "payments", "orders"}, order)
+			assert.Equal(t, "db", app.Get("ordersDb"))
+		}
+	}
+}
+
+func TestDependencies_KeepAddOrder(t *testing.T) {
+	var order []string
+	app, err := New("")
+	if assert.NoError(t, err) {
+		app.Add(
+			recordingModule("c", &order, nil, nil),
+			recordingModule("a", &order, nil, nil),
+			recordingModule("b", &order, nil, nil),
+		)
+
+		if assert.NoError(t, app.Init()) {
+			assert.Equal(t, []string{"c", "a", "b"}, order)
+		}
+	}
+}
+
+func TestDependencies_KnownKey(t *testing.T) {
+	var order []string
+	app, err := New("")
+	if assert.NoError(t, err) {
+		app.Set("config", "preset")
+		app.Add(recordingModule("orders", &order, nil, []Key{"config"}))
+		assert.NoError(t, app.Init())
+	}
+}
+
+func TestDependencies_MissingProvider(t *testing.T) {
+	var order []string
+	app, err := New("")
+	if assert.NoError(t, err) {
+		app.Add(
+			recordingModule("orders", &order, nil, []Key{"ordersDb"}),
+			recordingModule("payments", &order, nil, []Key{"ordersDb", "ledger"}),
+		)
+
+		err := app.Init()
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), `module orders requires "ordersDb"`)
+			assert.Contains(t, err.Error(), `module payments requires "ledger"`)
+			assert.Empty(t, order)
+		}
+	}
+}
+
+func TestDependencies_Cycle(t *testing.T) {
+	var order []string
+	app, err := New("")
+	if assert.NoError(t, err) {
+		app.Add(
+			recordingModule("standalone", &order, nil, nil),
+			recordingModule("a", &order, []Key{"a"}, []Key{"b"}),
+			recordingModule("b", &order, []Key{"b"}, []Key{"c"}),
+			recordingModule("c", &order, []Key{"c"}, []Key{"a"}),
+		)
+
+		err := app.Init()
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), "dependency cycle between modules")
+			assert.Contains(t, err.Error(), "a -> b -> c -> a")
+			assert.Empty(t, order)
+		}
+	}
+}
+
+func TestDependencies_DuplicateProvider(t *testing.T) {
+	var order []string
+	app, err := New("")
+	if assert.NoError(t, err) {
+		app.Add(
+			recordingModule("db1", &order, []Key{"ordersDb"}, nil),
+			recordingModule("db2", &order, []Key{"ordersDb"}, nil),
+		)
+
+		assert.EqualError(t, app.Init(), `key "ordersDb" is provided by both module db1 and module db2`)
+	}
+}
+
+func TestDependencies_ModuleNames(t *testing.T) {
+	appi, err := New("")
+	if assert.NoError(t, err) {
+		app := appi.(*defaultApplication)
+		assert.NoError(t, app.Add(MakeModule(), MakeModule(), MakeModule(Name("orders"))))
+		if assert.Len(t, app.modules, 3) {
+			assert.Equal(t, "go-app", app.modules[0].name)
+			assert.Equal(t, "go-app-2", app.modules[1].name)
+			assert.Equal(t, "orders", app.modules[2].name)
+		}
+
+		assert.EqualError(t, app.Add(MakeModule(Name("orders"))), "a module named orders was already added")
+	}
+}
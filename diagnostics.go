@@ -0,0 +1,175 @@
+package app
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/pprof"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+const (
+	// ActionStacks writes the stacks of all goroutines to a file
+	ActionStacks = "stacks"
+	// ActionReload reloads the sources of the config, like they changed
+	ActionReload = "reload"
+)
+
+// DefaultDiagnosticSignals are the signals that are handled when diagnostics.signals isn't configured
+var DefaultDiagnosticSignals = map[string]string{
+	"quit": ActionStacks,
+	"hup":  ActionReload,
+}
+
+// diagnosticSignals reads the signals and the action for each of them from the config
+//
+//	diagnostics:
+//	  dir: /var/tmp/orders
+//	  signals:
+//	    quit: stacks
+//	    hup: reload
+//	    usr1: heap
+//	    usr2: goroutine
+//
+// An action is stacks, reload or the name of a profile of the runtime/pprof package, eg. heap, goroutine or block.
+func (d *defaultApplication) diagnosticSignals() (map[os.Signal]string, []error) {
+	configured := DefaultDiagnosticSignals
+	if d.config.IsSet("diagnostics.signals") {
+		configured = d.config.GetStringMapString("diagnostics.signals")
+	}
+
+	var errs []error
+	actions := make(map[os.Signal]string, len(configured))
+	for name, action := range configured {
+		sig, ok := diagnosticSignalNames[strings.TrimPrefix(strings.ToLower(name), "sig")]
+		if !ok {
+			errs = append(errs, fmt.Errorf("signal %s can't be used for diagnostics, use one of %s", name, strings.Join(diagnosticSignalList(), ", ")))
+			continue
+		}
+		action = strings.ToLower(action)
+		if action != ActionStacks && action != ActionReload && pprof.Lookup(action) == nil {
+			errs = append(errs, fmt.Errorf("signal %s has an unknown action %q", name, action))
+			continue
+		}
+		actions[sig] = action
+	}
+	return actions, errs
+}
+
+// diagnosticsDir is where the stacks and profiles are written, the temp dir by default
+func (d *defaultApplication) diagnosticsDir() string {
+	if dir := d.config.GetString("diagnostics.dir"); dir != "" {
+		return dir
+	}
+	return os.TempDir()
+}
+
+// handleDiagnostics subscribes to the signals in the config, the signals of a previous config are released
+func (d *defaultApplication) handleDiagnostics() {
+	if !d.signals {
+		return
+	}
+	if d.stopDiagnostics != nil {
+		d.stopDiagnostics()
+		d.stopDiagnostics = nil
+	}
+
+	actions, errs := d.diagnosticSignals()
+	for _, err := range errs {
+		d.Logger().Errorf("diagnostics: %v", err)
+	}
+	if len(actions) == 0 {
+		return
+	}
+	dir := d.diagnosticsDir()
+	sigs := make([]os.Signal, 0, len(actions))
+	for sig := range actions {
+		sigs = append(sigs, sig)
+	}
+	d.stopDiagnostics = d.handleSignals(func(sig os.Signal) {
+		d.diagnose(sig, actions[sig], dir)
+	}, sigs...)
+}
+
+// diagnose runs the action for the signal
+func (d *defaultApplication) diagnose(sig os.Signal, action, dir string) {
+	switch action {
+	case ActionReload:
+		d.Logger().Infof("received %v, reloading the config", sig)
+		d.reloadSources(fsnotify.Event{Name: "signal " + sig.String(), Op: fsnotify.Write})
+	case ActionStacks:
+		path, err := d.writeDiagnostic(dir, action, "txt", func(f *os.File) error {
+			_, err := f.Write(allStacks())
+			return err
+		})
+		d.logDiagnostic(sig, action, path, err)
+	default:
+		path, err := d.writeDiagnostic(dir, action, "pb.gz", func(f *os.File) error {
+			return pprof.Lookup(action).WriteTo(f, 0)
+		})
+		d.logDiagnostic(sig, action, path, err)
+	}
+}
+
+func (d *defaultApplication) logDiagnostic(sig os.Signal, action, path string, err error) {
+	if err != nil {
+		d.Logger().Errorf("received %v, writing %s: %v", sig, action, err)
+		return
+	}
+	d.Logger().Infof("received %v, wrote %s to %s", sig, action, path)
+}
+
+// writeDiagnostic writes to a new file named after the application, the action and the current time
+func (d *defaultApplication) writeDiagnostic(dir, action, ext string, write func(*os.File) error) (string, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	app := strings.Replace(d.appInfo.Name, string(filepath.Separator), "_", -1)
+	name := fmt.Sprintf("%s-%s-%s.%s", app, action, time.Now().Format("20060102T150405.000000"), ext)
+	f, err := os.Create(filepath.Join(dir, name))
+	if err != nil {
+		return "", err
+	}
+	if err := write(f); err != nil {
+		f.Close()
+		return f.Name(), err
+	}
+	return f.Name(), f.Close()
+}
+
+// allStacks returns the stacks of all goroutines, the buffer grows until they fit
+func allStacks() []byte {
+	buf := make([]byte, 1<<20)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			return buf[:n]
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+}
+
+// reloadSources loads every source of the config again and applies them like a change of the config
+func (d *defaultApplication) reloadSources(evt fsnotify.Event) error {
+	var ids []int
+	for _, layer := range d.currentLayers() {
+		ids = append(ids, layer.id)
+	}
+	settings := d.loadSources(ids)
+	return d.updateLayers(evt, func(layers configLayers) configLayers { return layers.replaced(settings) }, nil)
+}
+
+// diagnosticSignalList is the sorted names of the signals that can be used for diagnostics
+func diagnosticSignalList() []string {
+	names := make([]string, 0, len(diagnosticSignalNames))
+	for name := range diagnosticSignalNames {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,96 @@
+package app
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiagnostics_Signals(t *testing.T) {
+	app, err := NewWithOptions("diagnostics", WithSignalHandling(false), WithWatchers(false))
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer app.Close()
+	d := app.(*defaultApplication)
+
+	actions, errs := d.diagnosticSignals()
+	assert.Empty(t, errs)
+	assert.Equal(t, map[os.Signal]string{
+		diagnosticSignalNames["quit"]: ActionStacks,
+		diagnosticSignalNames["hup"]:  ActionReload,
+	}, actions)
+
+	d.config.Set("diagnostics.signals", map[string]interface{}{
+		"SIGQUIT": "heap",
+		"hup":     "flamegraph",
+		"int":     "stacks",
+	})
+	actions, errs = d.diagnosticSignals()
+	assert.Equal(t, map[os.Signal]string{diagnosticSignalNames["quit"]: "heap"}, actions)
+	assert.Len(t, errs, 2)
+}
+
+func TestDiagnostics_Write(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-app")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	app, err := NewWithOptions("diagnostics", WithSignalHandling(false), WithWatchers(false))
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer app.Close()
+	d := app.(*defaultApplication)
+	d.config.Set("diagnostics.dir", filepath.Join(dir, "dumps"))
+
+	sig := diagnosticSignalNames["quit"]
+	d.diagnose(sig, ActionStacks, d.diagnosticsDir())
+	d.diagnose(sig, "heap", d.diagnosticsDir())
+
+	stacks, _ := filepath.Glob(filepath.Join(dir, "dumps", "diagnostics-stacks-*.txt"))
+	if assert.Len(t, stacks, 1) {
+		b, err := ioutil.ReadFile(stacks[0])
+		if assert.NoError(t, err) {
+			assert.True(t, strings.Contains(string(b), "TestDiagnostics_Write"))
+		}
+	}
+	heap, _ := filepath.Glob(filepath.Join(dir, "dumps", "diagnostics-heap-*.pb.gz"))
+	assert.Len(t, heap, 1)
+}
+
+func TestDiagnostics_Reload(t *testing.T) {
+	src := newMemorySource("memory", map[string]interface{}{"name": "first"})
+	app, err := NewWithOptions("diagnostics",
+		WithConfigSource(src, PriorityRemote),
+		WithSignalHandling(false),
+		WithWatchers(false),
+	)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer app.Close()
+	rec := new(eventRecorder)
+	app.Subscribe(rec.handle, EventConfigChanged)
+
+	// without watchers the change is only seen when the sources are reloaded
+	src.set(map[string]interface{}{"name": "second"})
+	assert.Equal(t, "first", app.Config().GetString("name"))
+
+	d := app.(*defaultApplication)
+	d.diagnose(diagnosticSignalNames["hup"], ActionReload, d.diagnosticsDir())
+	assert.Equal(t, "second", app.Config().GetString("name"))
+	if evts := rec.recorded(); assert.Len(t, evts, 1) {
+		assert.Equal(t, []string{"name"}, evts[0].Keys)
+	}
+
+	assert.NoError(t, d.reloadSources(fsnotify.Event{Name: "test", Op: fsnotify.Write}))
+	assert.Len(t, rec.recorded(), 1, "nothing changed, so no event is published")
+}
@@ -0,0 +1,17 @@
+//go:build !windows
+
+package app
+
+import (
+	"os"
+	"syscall"
+)
+
+// diagnosticSignalNames are the signals that can be configured for diagnostics,
+// SIGINT and SIGTERM are left for shutting down
+var diagnosticSignalNames = map[string]os.Signal{
+	"hup":  syscall.SIGHUP,
+	"quit": syscall.SIGQUIT,
+	"usr1": syscall.SIGUSR1,
+	"usr2": syscall.SIGUSR2,
+}
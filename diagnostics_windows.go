@@ -0,0 +1,13 @@
+package app
+
+import (
+	"os"
+	"syscall"
+)
+
+// diagnosticSignalNames are the signals that can be configured for diagnostics,
+// SIGINT and SIGTERM are left for shutting down
+var diagnosticSignalNames = map[string]os.Signal{
+	"hup":  syscall.SIGHUP,
+	"quit": syscall.SIGQUIT,
+}
@@ -0,0 +1,40 @@
+package app
+
+import "strings"
+
+// MultiError combines the errors of several modules, for example when a failed start
+// is followed by errors while stopping the modules that were already started.
+type MultiError []error
+
+// Error implements the error interface
+func (m MultiError) Error() string {
+	msgs := make([]string, 0, len(m))
+	for _, err := range m {
+		msgs = append(msgs, err.Error())
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Unwrap returns the combined errors so errors.Is and errors.As can inspect them
+func (m MultiError) Unwrap() []error {
+	return m
+}
+
+// joinErrors returns nil when there are no errors, the error itself when there is only one
+// and a MultiError otherwise
+func joinErrors(errs []error) error {
+	var res MultiError
+	for _, err := range errs {
+		if err != nil {
+			res = append(res, err)
+		}
+	}
+	switch len(res) {
+	case 0:
+		return nil
+	case 1:
+		return res[0]
+	default:
+		return res
+	}
+}
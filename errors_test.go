@@ -0,0 +1,23 @@
+package app
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMultiError(t *testing.T) {
+	assert.NoError(t, joinErrors(nil))
+	assert.NoError(t, joinErrors([]error{nil, nil}))
+
+	first := errors.New("first")
+	assert.Equal(t, first, joinErrors([]error{nil, first}))
+
+	err := joinErrors([]error{first, errors.New("second")})
+	if assert.Error(t, err) {
+		assert.EqualError(t, err, "first; second")
+		assert.True(t, errors.Is(err, first))
+		assert.Len(t, err.(MultiError), 2)
+	}
+}
@@ -0,0 +1,126 @@
+package app
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// EventKind tells what happened in an Event
+type EventKind string
+
+// The kinds of events the application publishes
+const (
+	// EventPhaseBegin is published right before a module runs a lifecycle phase
+	EventPhaseBegin EventKind = "phase.begin"
+	// EventPhaseEnd is published after a module ran a lifecycle phase, with its duration and error
+	EventPhaseEnd EventKind = "phase.end"
+	// EventConfigChanged is published when a changed config was validated, and the loggers and modules reloaded it
+	EventConfigChanged EventKind = "config.changed"
+	// EventConfigRejected is published when a changed config didn't pass validation, the previous config is kept
+	EventConfigRejected EventKind = "config.rejected"
+	// EventConfigRolledBack is published when a module failed to reload a changed config,
+	// the previous config was put back and reloaded
+	EventConfigRolledBack EventKind = "config.rolledback"
+)
+
+// Event describes something that happened in the lifecycle of the application
+type Event struct {
+	Kind EventKind
+	Time time.Time
+
+	// Module and Phase are set for phase events, Duration only when the phase ended
+	Module   string
+	Phase    Phase
+	Duration time.Duration
+	// Err is the error of a phase that ended, or the reason a config change was rejected or rolled back
+	Err error
+
+	// Source is the config file or remote provider that changed, and Keys the sorted keys that changed, for config events
+	Source string
+	Keys   []string
+}
+
+// EventHandler receives the events it subscribed to.
+// Handlers are called on the goroutine that publishes the event, so they should return quickly.
+// Modules can run their phases concurrently, so a handler can be called concurrently too.
+type EventHandler func(Event)
+
+type subscription struct {
+	handler EventHandler
+	kinds   map[EventKind]struct{}
+}
+
+func (s *subscription) wants(kind EventKind) bool {
+	if len(s.kinds) == 0 {
+		return true
+	}
+	_, ok := s.kinds[kind]
+	return ok
+}
+
+type eventBus struct {
+	lock sync.RWMutex
+	next int
+	subs map[int]*subscription
+}
+
+func newEventBus() *eventBus {
+	return &eventBus{subs: make(map[int]*subscription)}
+}
+
+func (b *eventBus) subscribe(handler EventHandler, kinds ...EventKind) func() {
+	sub := &subscription{handler: handler}
+	if len(kinds) > 0 {
+		sub.kinds = make(map[EventKind]struct{}, len(kinds))
+		for _, k := range kinds {
+			sub.kinds[k] = struct{}{}
+		}
+	}
+
+	b.lock.Lock()
+	id := b.next
+	b.next++
+	b.subs[id] = sub
+	b.lock.Unlock()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			b.lock.Lock()
+			delete(b.subs, id)
+			b.lock.Unlock()
+		})
+	}
+}
+
+// publish the event to the subscribers, in the order they subscribed
+func (b *eventBus) publish(evt Event) {
+	if evt.Time.IsZero() {
+		evt.Time = time.Now()
+	}
+
+	b.lock.RLock()
+	ids := make([]int, 0, len(b.subs))
+	for id, sub := range b.subs {
+		if sub.wants(evt.Kind) {
+			ids = append(ids, id)
+		}
+	}
+	subs := make([]*subscription, 0, len(ids))
+	sort.Ints(ids)
+	for _, id := range ids {
+		subs = append(subs, b.subs[id])
+	}
+	b.lock.RUnlock()
+
+	for _, sub := range subs {
+		sub.handler(evt)
+	}
+}
+
+// Subscribe calls the handler for every event of the kinds, or for every event when no kinds are passed.
+// The returned function ends the subscription.
+func (d *defaultApplication) Subscribe(handler EventHandler, kinds ...EventKind) func() {
+	return d.events.subscribe(handler, kinds...)
+}
@@ -0,0 +1,114 @@
+package app
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type eventRecorder struct {
+	lock   sync.Mutex
+	events []Event
+}
+
+func (r *eventRecorder) handle(evt Event) {
+	r.lock.Lock()
+	r.events = append(r.events, evt)
+	r.lock.Unlock()
+}
+
+func (r *eventRecorder) recorded() []Event {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return append([]Event(nil), r.events...)
+}
+
+func TestEvents_Phases(t *testing.T) {
+	app, err := New("")
+	if assert.NoError(t, err) {
+		rec := new(eventRecorder)
+		unsubscribe := app.Subscribe(rec.handle)
+		app.Add(
+			MakeModule(Name("orders")),
+			MakeModule(Name("broken"), Start(func(_ Application) error { return errors.New("no port") })),
+		)
+
+		assert.NoError(t, app.Init())
+		assert.Error(t, app.Start())
+
+		var seen []string
+		for _, evt := range rec.recorded() {
+			assert.False(t, evt.Time.IsZero())
+			seen = append(seen, string(evt.Kind)+" "+string(evt.Phase)+" "+evt.Module)
+		}
+		assert.Equal(t, []string{
+			"phase.begin init orders",
+			"phase.end init orders",
+			"phase.begin init broken",
+			"phase.end init broken",
+			"phase.begin start orders",
+			"phase.end start orders",
+			"phase.begin start broken",
+			"phase.end start broken",
+			"phase.begin stop orders",
+			"phase.end stop orders",
+		}, seen)
+
+		failed := rec.recorded()[7]
+		assert.EqualError(t, failed.Err, "start broken: no port")
+
+		unsubscribe()
+		unsubscribe()
+		assert.NoError(t, app.Stop())
+		assert.Len(t, rec.recorded(), 10)
+	}
+}
+
+func TestEvents_Kinds(t *testing.T) {
+	app, err := New("")
+	if assert.NoError(t, err) {
+		rec := new(eventRecorder)
+		app.Subscribe(rec.handle, EventPhaseEnd)
+		app.Add(MakeModule(Name("orders")))
+
+		assert.NoError(t, app.Init())
+		events := rec.recorded()
+		if assert.Len(t, events, 1) {
+			assert.Equal(t, EventPhaseEnd, events[0].Kind)
+			assert.Equal(t, "orders", events[0].Module)
+			assert.NoError(t, events[0].Err)
+		}
+	}
+}
+
+func TestEvents_ConfigChanged(t *testing.T) {
+	err := ioutil.WriteFile("config.json", []byte(`{"name": "some value"}`), 0644)
+	defer os.Remove("config.json")
+
+	if assert.NoError(t, err) {
+		app, err := New("")
+		if assert.NoError(t, err) {
+			changed := make(chan Event, 10)
+			app.Subscribe(func(evt Event) { changed <- evt }, EventConfigChanged)
+
+			go func() {
+				<-time.After(1 * time.Second)
+				if err := ioutil.WriteFile("config.json", []byte(`{"name": "other value"}`), 0644); err != nil {
+					t.Log(err)
+				}
+			}()
+			select {
+			case evt := <-changed:
+				assert.Equal(t, EventConfigChanged, evt.Kind)
+				assert.Contains(t, evt.Source, "config.json")
+			case <-time.After(5 * time.Second):
+				t.Fatal("no config changed event")
+			}
+		}
+	}
+}
@@ -0,0 +1,40 @@
+module github.com/casualjim/go-app
+
+go 1.20
+
+require (
+	github.com/fsnotify/fsnotify v1.7.0
+	github.com/kardianos/osext v0.0.0-20170510131534-ae77be60afb1
+	github.com/kr/pretty v0.3.1
+	github.com/rcrowley/go-metrics v0.0.0-20180503174638-e2704e165165
+	github.com/sirupsen/logrus v1.8.1
+	github.com/spf13/cast v1.6.0
+	github.com/spf13/pflag v1.0.5
+	github.com/spf13/viper v1.0.2
+	github.com/stretchr/testify v1.8.4
+	github.com/xordataexchange/crypt v0.0.3-0.20170626215501-b2862e3d0a77
+	gopkg.in/yaml.v2 v2.4.0
+)
+
+require (
+	github.com/armon/consul-api v0.0.0-20180202201655-eb2c6b5be1b6 // indirect
+	github.com/coreos/etcd v3.3.27+incompatible // indirect
+	github.com/coreos/go-semver v0.3.1 // indirect
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/hashicorp/hcl v1.0.0 // indirect
+	github.com/json-iterator/go v1.1.12 // indirect
+	github.com/kr/text v0.2.0 // indirect
+	github.com/magiconair/properties v1.8.7 // indirect
+	github.com/mitchellh/mapstructure v1.5.0 // indirect
+	github.com/modern-go/concurrent v0.0.0-20180306012644-bacd9c7ef1dd // indirect
+	github.com/modern-go/reflect2 v1.0.2 // indirect
+	github.com/pelletier/go-toml v1.9.5 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	github.com/rogpeppe/go-internal v1.9.0 // indirect
+	github.com/spf13/afero v1.11.0 // indirect
+	github.com/spf13/jwalterweatherman v1.1.0 // indirect
+	golang.org/x/crypto v0.16.0 // indirect
+	golang.org/x/sys v0.15.0 // indirect
+	golang.org/x/text v0.14.0 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
+)
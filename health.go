@@ -0,0 +1,234 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// DefaultHealthTimeout is how long a single health or readiness check gets when no timeout is configured
+const DefaultHealthTimeout = 5 * time.Second
+
+// HealthChecker is implemented by modules that can tell whether they still work.
+// A module that fails its health check is not expected to recover without a restart.
+type HealthChecker interface {
+	CheckHealth(context.Context, Application) error
+}
+
+// ReadinessChecker is implemented by modules that can tell whether they are ready to receive work.
+// A module that isn't ready might become ready later, eg. once a connection is restored.
+type ReadinessChecker interface {
+	CheckReady(context.Context, Application) error
+}
+
+// HealthCheck declares the health check of a module made with MakeModule
+type HealthCheck func(context.Context, Application) error
+
+// Call implements the callback interface, checks don't run as part of the lifecycle
+func (HealthCheck) Call(_ Application) error {
+	return nil
+}
+
+// ReadinessCheck declares the readiness check of a module made with MakeModule
+type ReadinessCheck func(context.Context, Application) error
+
+// Call implements the callback interface, checks don't run as part of the lifecycle
+func (ReadinessCheck) Call(_ Application) error {
+	return nil
+}
+
+// HealthStatus summarizes the outcome of a group of checks
+type HealthStatus string
+
+// The possible outcomes of a group of checks
+const (
+	// HealthUp means every check passed
+	HealthUp HealthStatus = "up"
+	// HealthDegraded means only non critical checks failed
+	HealthDegraded HealthStatus = "degraded"
+	// HealthDown means at least one critical check failed
+	HealthDown HealthStatus = "down"
+)
+
+// CheckKind is the kind of check a result belongs to
+type CheckKind string
+
+// The kinds of checks
+const (
+	CheckHealth    CheckKind = "health"
+	CheckReadiness CheckKind = "readiness"
+)
+
+// CheckResult is the outcome of a single check
+type CheckResult struct {
+	Module   string
+	Kind     CheckKind
+	Critical bool
+	Err      error
+	Duration time.Duration
+	// CheckedAt is when the check ran, Cached is true when the result was reused from an earlier run
+	CheckedAt time.Time
+	Cached    bool
+}
+
+// MarshalJSON writes the error as a string and the duration in a readable format
+func (c CheckResult) MarshalJSON() ([]byte, error) {
+	var errMsg string
+	if c.Err != nil {
+		errMsg = c.Err.Error()
+	}
+	return json.Marshal(struct {
+		Module    string    `json:"module"`
+		Kind      CheckKind `json:"kind"`
+		Critical  bool      `json:"critical"`
+		Error     string    `json:"error,omitempty"`
+		Duration  string    `json:"duration"`
+		CheckedAt time.Time `json:"checkedAt"`
+		Cached    bool      `json:"cached,omitempty"`
+	}{c.Module, c.Kind, c.Critical, errMsg, c.Duration.String(), c.CheckedAt, c.Cached})
+}
+
+// HealthReport is the aggregated result of the health and readiness checks of the modules
+type HealthReport struct {
+	// Live is down when a critical health check fails, and degraded when only non critical health checks fail
+	Live HealthStatus `json:"live"`
+	// Ready is down when the application isn't started, when it isn't live,
+	// or when a critical readiness check fails
+	Ready  HealthStatus  `json:"ready"`
+	Checks []CheckResult `json:"checks"`
+}
+
+// healthCheckers returns the checks a module implements
+func healthCheckers(mod Module) (HealthChecker, ReadinessChecker) {
+	if dm, ok := mod.(*dynamicModule); ok {
+		// modules made with MakeModule always implement the interfaces, but only some declare checks
+		var hc HealthChecker
+		var rc ReadinessChecker
+		if len(dm.health) > 0 {
+			hc = dm
+		}
+		if len(dm.ready) > 0 {
+			rc = dm
+		}
+		return hc, rc
+	}
+	hc, _ := mod.(HealthChecker)
+	rc, _ := mod.(ReadinessChecker)
+	return hc, rc
+}
+
+// Health runs the health and readiness checks of the modules that are initialized, and aggregates them in a report.
+// The checks run concurrently, each one is limited to its own timeout, configured as
+//
+//	health:
+//	  timeout: 5s
+//	  cache: 10s
+//	modules:
+//	  orders:
+//	    health:
+//	      timeout: 1s
+//	      critical: false
+//
+// When a cache duration is configured, the result of a check is reused until it expires.
+// Checks are critical unless configured otherwise.
+func (d *defaultApplication) Health(ctx context.Context) HealthReport {
+	mods, err := sortModules(d.modules, d.hasKey)
+	if err != nil {
+		mods = d.modules
+	}
+
+	type pending struct {
+		mod   *registration
+		kind  CheckKind
+		check func(context.Context, Application) error
+	}
+	var checks []pending
+	for _, mod := range mods {
+		if !mod.isInitialized() {
+			continue
+		}
+		hc, rc := healthCheckers(mod.module)
+		if hc != nil {
+			checks = append(checks, pending{mod, CheckHealth, hc.CheckHealth})
+		}
+		if rc != nil {
+			checks = append(checks, pending{mod, CheckReadiness, rc.CheckReady})
+		}
+	}
+
+	results := make([]CheckResult, len(checks))
+	var wg sync.WaitGroup
+	for i, c := range checks {
+		wg.Add(1)
+		go func(i int, c pending) {
+			defer wg.Done()
+			results[i] = d.runCheck(ctx, c.mod, c.kind, c.check)
+		}(i, c)
+	}
+	wg.Wait()
+
+	report := HealthReport{Live: HealthUp, Ready: HealthUp, Checks: results}
+	for _, res := range results {
+		if res.Err == nil {
+			continue
+		}
+		status := &report.Live
+		if res.Kind == CheckReadiness {
+			status = &report.Ready
+		}
+		if res.Critical {
+			*status = HealthDown
+		} else if *status == HealthUp {
+			*status = HealthDegraded
+		}
+	}
+
+	d.lifecycleLock.Lock()
+	started := d.started
+	d.lifecycleLock.Unlock()
+	if !started || report.Live == HealthDown {
+		report.Ready = HealthDown
+	}
+	return report
+}
+
+func (d *defaultApplication) runCheck(ctx context.Context, mod *registration, kind CheckKind, check func(context.Context, Application) error) CheckResult {
+	critical := true
+	if key := "modules." + mod.name + ".health.critical"; d.config.IsSet(key) {
+		critical = d.config.GetBool(key)
+	}
+
+	if res, ok := mod.cachedCheck(kind, d.config.GetDuration("health.cache")); ok {
+		res.Critical = critical
+		res.Cached = true
+		return res
+	}
+
+	timeout := d.config.GetDuration("modules." + mod.name + ".health.timeout")
+	if timeout <= 0 {
+		timeout = d.config.GetDuration("health.timeout")
+	}
+	if timeout <= 0 {
+		timeout = DefaultHealthTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	res := CheckResult{Module: mod.name, Kind: kind, Critical: critical, CheckedAt: time.Now()}
+	done := make(chan error, 1)
+	go func() {
+		done <- check(ctx, d)
+	}()
+	select {
+	case res.Err = <-done:
+	case <-ctx.Done():
+		// like a lifecycle phase, a check that hangs keeps running in the background
+		res.Err = fmt.Errorf("no result after %v: %w", timeout, ctx.Err())
+	}
+	res.Duration = time.Since(res.CheckedAt)
+
+	mod.cacheCheck(res)
+	return res
+}
@@ -0,0 +1,130 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type checkedModule struct {
+	healthy error
+	ready   error
+}
+
+func (c *checkedModule) Init(_ Application) error   { return nil }
+func (c *checkedModule) Start(_ Application) error  { return nil }
+func (c *checkedModule) Stop(_ Application) error   { return nil }
+func (c *checkedModule) Reload(_ Application) error { return nil }
+func (c *checkedModule) Name() string               { return "checked" }
+
+func (c *checkedModule) CheckHealth(_ context.Context, _ Application) error { return c.healthy }
+func (c *checkedModule) CheckReady(_ context.Context, _ Application) error  { return c.ready }
+
+func TestHealth_Report(t *testing.T) {
+	app, err := New("")
+	if assert.NoError(t, err) {
+		checked := new(checkedModule)
+		app.Add(
+			checked,
+			MakeModule(Name("orders"), HealthCheck(func(_ context.Context, _ Application) error { return nil })),
+			MakeModule(Name("plain")),
+		)
+
+		// nothing is checked before the modules are initialized
+		report := app.Health(context.Background())
+		assert.Empty(t, report.Checks)
+		assert.Equal(t, HealthDown, report.Ready)
+
+		assert.NoError(t, app.Init())
+		assert.NoError(t, app.Start())
+
+		report = app.Health(context.Background())
+		assert.Equal(t, HealthUp, report.Live)
+		assert.Equal(t, HealthUp, report.Ready)
+		if assert.Len(t, report.Checks, 3) {
+			assert.Equal(t, "checked", report.Checks[0].Module)
+			assert.Equal(t, CheckHealth, report.Checks[0].Kind)
+			assert.Equal(t, CheckReadiness, report.Checks[1].Kind)
+			assert.Equal(t, "orders", report.Checks[2].Module)
+			assert.True(t, report.Checks[2].Critical)
+		}
+
+		checked.ready = errors.New("warming up")
+		report = app.Health(context.Background())
+		assert.Equal(t, HealthUp, report.Live)
+		assert.Equal(t, HealthDown, report.Ready)
+
+		checked.ready = nil
+		checked.healthy = errors.New("broken")
+		report = app.Health(context.Background())
+		assert.Equal(t, HealthDown, report.Live)
+		assert.Equal(t, HealthDown, report.Ready)
+		assert.EqualError(t, report.Checks[0].Err, "broken")
+	}
+}
+
+func TestHealth_NonCritical(t *testing.T) {
+	app, err := New("")
+	if assert.NoError(t, err) {
+		app.Config().Set("modules.cache.health.critical", false)
+		app.Add(MakeModule(Name("cache"), ReadinessCheck(func(_ context.Context, _ Application) error {
+			return errors.New("cold")
+		})))
+
+		assert.NoError(t, app.Init())
+		assert.NoError(t, app.Start())
+
+		report := app.Health(context.Background())
+		assert.Equal(t, HealthUp, report.Live)
+		assert.Equal(t, HealthDegraded, report.Ready)
+		if assert.Len(t, report.Checks, 1) {
+			assert.False(t, report.Checks[0].Critical)
+		}
+	}
+}
+
+func TestHealth_Timeout(t *testing.T) {
+	app, err := New("")
+	if assert.NoError(t, err) {
+		app.Config().Set("modules.slow.health.timeout", "20ms")
+		app.Add(MakeModule(Name("slow"), HealthCheck(func(ctx context.Context, _ Application) error {
+			<-ctx.Done()
+			time.Sleep(50 * time.Millisecond)
+			return nil
+		})))
+
+		assert.NoError(t, app.Init())
+		report := app.Health(context.Background())
+		assert.Equal(t, HealthDown, report.Live)
+		if assert.Len(t, report.Checks, 1) {
+			assert.True(t, errors.Is(report.Checks[0].Err, context.DeadlineExceeded))
+			assert.Contains(t, report.Checks[0].Err.Error(), "no result after 20ms")
+		}
+	}
+}
+
+func TestHealth_Cache(t *testing.T) {
+	app, err := New("")
+	if assert.NoError(t, err) {
+		app.Config().Set("health.cache", "1h")
+		var calls int32
+		app.Add(MakeModule(Name("counted"), HealthCheck(func(_ context.Context, _ Application) error {
+			atomic.AddInt32(&calls, 1)
+			return nil
+		})))
+
+		assert.NoError(t, app.Init())
+		first := app.Health(context.Background())
+		second := app.Health(context.Background())
+		assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+		if assert.Len(t, second.Checks, 1) {
+			assert.False(t, first.Checks[0].Cached)
+			assert.True(t, second.Checks[0].Cached)
+			assert.Equal(t, first.Checks[0].CheckedAt, second.Checks[0].CheckedAt)
+		}
+	}
+}
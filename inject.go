@@ -0,0 +1,120 @@
+package app
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// injectTag is the struct tag used by Inject
+const injectTag = "app"
+
+// Inject fills the exported fields of the struct the target points to from the application.
+// Fields are tagged with the key of their value, the tag supports these options:
+//
+//	type ordersService struct {
+//		DB     OrdersStore        `app:"ordersDb"`          // the value at ordersDb, an error when missing
+//		Cache  Cache              `app:"cache,optional"`    // left alone when nothing is registered at cache
+//		Log    logrus.FieldLogger `app:"orders,logger"`     // the logger from NewLogger("orders", nil)
+//		Tracer tracing.Tracer     `app:",tracer"`           // the tracer of the application
+//	}
+func (d *defaultApplication) Inject(target interface{}) error {
+	return inject(d, target)
+}
+
+// Inject uses the application that tracks the keys being built, so injecting in a provider detects cycles
+func (r *resolvingApplication) Inject(target interface{}) error {
+	return inject(r, target)
+}
+
+func inject(a Application, target interface{}) error {
+	ptr := reflect.ValueOf(target)
+	if ptr.Kind() != reflect.Ptr || ptr.IsNil() || ptr.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("inject: target must be a non-nil pointer to a struct, got %T", target)
+	}
+
+	val := ptr.Elem()
+	tpe := val.Type()
+	var errs []error
+	for i := 0; i < tpe.NumField(); i++ {
+		field := tpe.Field(i)
+		tag, ok := field.Tag.Lookup(injectTag)
+		if !ok || tag == "-" {
+			continue
+		}
+		name := field.Name
+		if tpe.Name() != "" {
+			name = tpe.Name() + "." + name
+		}
+		if field.PkgPath != "" {
+			errs = append(errs, fmt.Errorf("inject %s: field is not exported", name))
+			continue
+		}
+		if err := injectField(a, val.Field(i), tag); err != nil {
+			errs = append(errs, fmt.Errorf("inject %s: %w", name, err))
+		}
+	}
+	return joinErrors(errs)
+}
+
+func injectField(a Application, field reflect.Value, tag string) error {
+	parts := strings.Split(tag, ",")
+	key := Key(strings.TrimSpace(parts[0]))
+	var optional, logger, tracer bool
+	for _, opt := range parts[1:] {
+		switch strings.TrimSpace(opt) {
+		case "optional":
+			optional = true
+		case "logger":
+			logger = true
+		case "tracer":
+			tracer = true
+		case "":
+		default:
+			return fmt.Errorf("unknown tag option %q", opt)
+		}
+	}
+
+	var value interface{}
+	switch {
+	case logger:
+		if key == "" {
+			value = a.Logger()
+		} else {
+			value = a.NewLogger(string(key), nil)
+		}
+	case tracer:
+		value = a.Tracer()
+	default:
+		if key == "" {
+			return fmt.Errorf("tag has no key")
+		}
+		var ok bool
+		var err error
+		if value, ok, err = lookupKey(a, key); err != nil {
+			return err
+		}
+		if !ok {
+			if optional {
+				return nil
+			}
+			return fmt.Errorf("%w: %q", ErrModuleUnknown, key)
+		}
+	}
+
+	if value == nil {
+		switch field.Kind() {
+		case reflect.Ptr, reflect.Interface, reflect.Map, reflect.Slice, reflect.Func, reflect.Chan:
+			field.Set(reflect.Zero(field.Type()))
+			return nil
+		}
+		return &TypeMismatchError{Key: key, Expected: field.Type()}
+	}
+
+	rv := reflect.ValueOf(value)
+	if !rv.Type().AssignableTo(field.Type()) {
+		return &TypeMismatchError{Key: key, Expected: field.Type(), Actual: rv.Type()}
+	}
+	field.Set(rv)
+	return nil
+}
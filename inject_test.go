@@ -0,0 +1,111 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/casualjim/go-app/logging"
+	"github.com/casualjim/go-app/tracing"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+type injectTarget struct {
+	First    *firstModule       `app:"firstModule"`
+	Count    int                `app:"count"`
+	Optional fmt.Stringer       `app:"missing,optional"`
+	Log      logrus.FieldLogger `app:"orders,logger"`
+	Root     logging.Logger     `app:",logger"`
+	Tracer   tracing.Tracer     `app:",tracer"`
+	Ignored  string             `app:"-"`
+	Untagged string
+}
+
+func TestInject(t *testing.T) {
+	app, err := New("InjectTest")
+	if assert.NoError(t, err) {
+		fm := &firstModule{arb: "value"}
+		app.Set(firstModuleKey, fm)
+		app.Set("count", 3)
+
+		var target injectTarget
+		target.Untagged = "untouched"
+		if assert.NoError(t, app.Inject(&target)) {
+			assert.Equal(t, fm, target.First)
+			assert.Equal(t, 3, target.Count)
+			assert.Nil(t, target.Optional)
+			assert.Equal(t, "untouched", target.Untagged)
+			assert.Equal(t, app.Tracer(), target.Tracer)
+			assert.Equal(t, app.Logger(), target.Root)
+			if assert.NotNil(t, target.Log) {
+				assert.Equal(t, "orders", target.Log.(logging.Logger).Fields()["module"])
+			}
+		}
+	}
+}
+
+func TestInject_Providers(t *testing.T) {
+	app, err := New("")
+	if assert.NoError(t, err) {
+		assert.NoError(t, Provide(app, "count", func(_ Application) (int, error) { return 5, nil }))
+		assert.NoError(t, Provide(app, "loop", func(a Application) (int, error) {
+			var target struct {
+				Loop int `app:"loop"`
+			}
+			return target.Loop, a.Inject(&target)
+		}))
+
+		var target struct {
+			Count int `app:"count"`
+		}
+		if assert.NoError(t, app.Inject(&target)) {
+			assert.Equal(t, 5, target.Count)
+		}
+
+		var looping struct {
+			Loop int `app:"loop"`
+		}
+		err := app.Inject(&looping)
+		assert.True(t, errors.Is(err, ErrCyclicProvider))
+	}
+}
+
+func TestInject_Errors(t *testing.T) {
+	app, err := New("")
+	if assert.NoError(t, err) {
+		app.Set("count", "three")
+		app.Set("nothing", nil)
+
+		assert.EqualError(t, app.Inject(nil), "inject: target must be a non-nil pointer to a struct, got <nil>")
+		assert.Error(t, app.Inject(injectTarget{}))
+		var notStruct int
+		assert.Error(t, app.Inject(&notStruct))
+
+		var target struct {
+			Count    int    `app:"count"`
+			Missing  string `app:"missing"`
+			Nothing  int    `app:"nothing"`
+			NoKey    string `app:",optional"`
+			BadOpt   string `app:"count,sometimes"`
+			hidden   string `app:"count"`
+			Nillable *int   `app:"nothing"`
+		}
+		err := app.Inject(&target)
+		if assert.Error(t, err) {
+			errs, ok := err.(MultiError)
+			if assert.True(t, ok) && assert.Len(t, errs, 6) {
+				assert.EqualError(t, errs[0], `inject Count: key "count": expected a value of type int, but found string`)
+				assert.EqualError(t, errs[1], `inject Missing: unknown module: "missing"`)
+				assert.EqualError(t, errs[2], `inject Nothing: key "nothing": expected a value of type int, but found <nil>`)
+				assert.EqualError(t, errs[3], `inject NoKey: tag has no key`)
+				assert.EqualError(t, errs[4], `inject BadOpt: unknown tag option "sometimes"`)
+				assert.EqualError(t, errs[5], `inject hidden: field is not exported`)
+			}
+			var mismatch *TypeMismatchError
+			assert.True(t, errors.As(err, &mismatch))
+			assert.True(t, errors.Is(err, ErrModuleUnknown))
+		}
+		assert.Empty(t, target.hidden)
+	}
+}
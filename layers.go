@@ -0,0 +1,339 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/spf13/viper"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// remoteProvider describes a remote config, it implements viper.RemoteProvider
+type remoteProvider struct {
+	provider string
+	endpoint string
+	path     string
+	keyring  string
+	url      string
+	tpe      string
+}
+
+func (r *remoteProvider) Provider() string      { return r.provider }
+func (r *remoteProvider) Endpoint() string      { return r.endpoint }
+func (r *remoteProvider) Path() string          { return r.path }
+func (r *remoteProvider) SecretKeyring() string { return r.keyring }
+
+// parseRemoteURL parses the url of a remote config, the extension of the path is the type of the document
+//
+//	etcd://localhost:2379/[app-name]/config.[type]
+//	consul://localhost:8500/[app-name]/config.[type]
+func parseRemoteURL(remURL, keyring string) (*remoteProvider, error) {
+	u, err := url.Parse(remURL)
+	if err != nil {
+		return nil, err
+	}
+
+	rp := &remoteProvider{
+		provider: strings.ToLower(u.Scheme),
+		endpoint: u.Host,
+		path:     u.Path,
+		keyring:  keyring,
+		url:      remURL,
+		tpe:      strings.ToLower(strings.TrimLeft(filepath.Ext(u.Path), ".")),
+	}
+	if rp.provider == "etcd" {
+		rp.endpoint = "http://" + rp.endpoint
+	}
+	if rp.tpe == "" {
+		rp.tpe = "json"
+	}
+	return rp, nil
+}
+
+// fetch the current remote config
+func (r *remoteProvider) fetch() (map[string]interface{}, error) {
+	if viper.RemoteConfig == nil {
+		return nil, fmt.Errorf("remote config providers are not enabled")
+	}
+	rdr, err := viper.RemoteConfig.Get(r)
+	if err != nil {
+		return nil, err
+	}
+	return parseSettings(rdr, r.tpe)
+}
+
+// watch calls changed every time the remote config changes, until the context is done or the watch fails
+func (r *remoteProvider) watch(ctx context.Context, changed func()) error {
+	if viper.RemoteConfig == nil {
+		return fmt.Errorf("remote config providers are not enabled")
+	}
+	responses, quit := viper.RemoteConfig.WatchChannel(r)
+	if responses == nil {
+		return fmt.Errorf("can't connect to the %s provider at %s", r.provider, r.endpoint)
+	}
+	defer func() {
+		// the watch might be delivering a response, so keep draining until it accepts to quit
+		for {
+			select {
+			case quit <- true:
+				return
+			case <-responses:
+			}
+		}
+	}()
+
+	for {
+		select {
+		case resp := <-responses:
+			if resp.Error != nil {
+				return resp.Error
+			}
+			changed()
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// parseSettings parses a config document of the type, eg. yaml
+func parseSettings(rdr io.Reader, tpe string) (map[string]interface{}, error) {
+	v := viper.New()
+	v.SetConfigType(tpe)
+	if err := v.ReadConfig(rdr); err != nil {
+		return nil, fmt.Errorf("config is invalid as %s: %v", tpe, err)
+	}
+	return v.AllSettings(), nil
+}
+
+// readSettingsFile parses the config file, the type is taken from its extension
+func readSettingsFile(file string) (map[string]interface{}, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	return parseSettings(bytes.NewReader(data), strings.ToLower(strings.TrimLeft(filepath.Ext(file), ".")))
+}
+
+func supportedConfigType(file string) bool {
+	tpe := strings.ToLower(strings.TrimLeft(filepath.Ext(file), "."))
+	for _, ext := range viper.SupportedExts {
+		if ext == tpe {
+			return true
+		}
+	}
+	return false
+}
+
+// configLayer is the settings a config source loaded, the id identifies the source in the application
+type configLayer struct {
+	id       int
+	name     string
+	priority Priority
+	source   ConfigSource
+	settings map[string]interface{}
+}
+
+// configLayers are the documents the config of the application is read from, ordered by priority.
+// Layers with the same priority are ordered by when their source was added.
+type configLayers []configLayer
+
+func (l configLayers) empty() bool {
+	for _, layer := range l {
+		if layer.settings != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// merged combines the layers, nested maps are merged and other values are replaced
+func (l configLayers) merged() map[string]interface{} {
+	res := make(map[string]interface{})
+	for _, layer := range l {
+		mergeSettings(res, layer.settings)
+	}
+	return res
+}
+
+// with returns a copy of the layers with the layer added after the layers of the same or a lower priority
+func (l configLayers) with(layer configLayer) configLayers {
+	res := make(configLayers, 0, len(l)+1)
+	at := sort.Search(len(l), func(i int) bool { return l[i].priority > layer.priority })
+	res = append(res, l[:at]...)
+	res = append(res, layer)
+	return append(res, l[at:]...)
+}
+
+// replaced returns a copy of the layers with new settings for the layers with the ids
+func (l configLayers) replaced(settings map[int]map[string]interface{}) configLayers {
+	res := make(configLayers, len(l))
+	copy(res, l)
+	for i := range res {
+		if s, ok := settings[res[i].id]; ok {
+			res[i].settings = s
+		}
+	}
+	return res
+}
+
+// reverted returns a copy of the layers with the change from prev to next undone.
+// Layers that changed again after next are kept as they are.
+func (l configLayers) reverted(prev, next configLayers) configLayers {
+	before := make(map[int]configLayer, len(prev))
+	for _, layer := range prev {
+		before[layer.id] = layer
+	}
+	after := make(map[int]configLayer, len(next))
+	for _, layer := range next {
+		after[layer.id] = layer
+	}
+
+	var res configLayers
+	seen := make(map[int]bool, len(l))
+	for _, layer := range l {
+		seen[layer.id] = true
+		changed, ok := after[layer.id]
+		if !ok || !sameSettings(layer.settings, changed.settings) {
+			res = append(res, layer)
+			continue
+		}
+		if old, ok := before[layer.id]; ok {
+			res = append(res, old)
+		}
+		// a layer the change added is dropped
+	}
+	for _, layer := range prev {
+		// a layer the change removed is added again
+		if _, ok := after[layer.id]; !ok && !seen[layer.id] {
+			res = res.with(layer)
+		}
+	}
+	return res
+}
+
+// sameSettings is true when both are the same map, settings are replaced and never modified
+func sameSettings(a, b map[string]interface{}) bool {
+	return reflect.ValueOf(a).Pointer() == reflect.ValueOf(b).Pointer()
+}
+
+// origin is the name of the layer with the highest priority that sets the key
+func (l configLayers) origin(key string) (string, bool) {
+	path := strings.Split(strings.ToLower(key), ".")
+	for i := len(l) - 1; i >= 0; i-- {
+		if hasPath(l[i].settings, path) {
+			return l[i].name, true
+		}
+	}
+	return "", false
+}
+
+func hasPath(settings map[string]interface{}, path []string) bool {
+	var cur interface{} = settings
+	for _, part := range path {
+		m, ok := toStringMap(cur)
+		if !ok {
+			return false
+		}
+		if cur, ok = m[part]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func mergeSettings(dst, src map[string]interface{}) {
+	for k, v := range src {
+		sm, srcIsMap := toStringMap(v)
+		dm, dstIsMap := toStringMap(dst[k])
+		if srcIsMap && dstIsMap {
+			merged := make(map[string]interface{}, len(dm)+len(sm))
+			mergeSettings(merged, dm)
+			mergeSettings(merged, sm)
+			dst[k] = merged
+			continue
+		}
+		if srcIsMap {
+			cp := make(map[string]interface{}, len(sm))
+			mergeSettings(cp, sm)
+			dst[k] = cp
+			continue
+		}
+		dst[k] = v
+	}
+}
+
+// toStringMap converts the maps the different config formats decode into, yaml uses interface keys
+func toStringMap(v interface{}) (map[string]interface{}, bool) {
+	switch m := v.(type) {
+	case map[string]interface{}:
+		return m, true
+	case map[interface{}]interface{}:
+		res := make(map[string]interface{}, len(m))
+		for k, vv := range m {
+			res[strings.ToLower(fmt.Sprint(k))] = vv
+		}
+		return res, true
+	}
+	return nil, false
+}
+
+// applyLayers replaces the config layer of viper with the merged layers, after the secrets of local sources are resolved.
+// It returns the keys of the values that contain a secret.
+func applyLayers(cfg *viper.Viper, layers configLayers) (sensitiveKeys, error) {
+	data, sensitive, err := renderLayers(layers)
+	if err != nil {
+		return nil, err
+	}
+	return sensitive, readLayers(cfg, data)
+}
+
+// renderLayers merges the layers into a yaml document, after the secrets of local sources are resolved.
+// It returns the keys of the values that contain a secret, the document is nil when there are no layers.
+func renderLayers(layers configLayers) ([]byte, sensitiveKeys, error) {
+	if layers.empty() {
+		return nil, nil, nil
+	}
+	resolved, sensitive, err := layers.resolvedSecrets()
+	if err != nil {
+		return nil, nil, err
+	}
+	data, err := yaml.Marshal(resolved.merged())
+	if err != nil {
+		return nil, nil, err
+	}
+	return data, sensitive, nil
+}
+
+// readLayers replaces the config layer of viper with a document of renderLayers.
+// The layers are read as yaml, which is why the config type of the application config is yaml from here on.
+func readLayers(cfg *viper.Viper, data []byte) error {
+	if data == nil {
+		return nil
+	}
+	viperLock.Lock()
+	defer viperLock.Unlock()
+	cfg.SetConfigType("yaml")
+	return cfg.ReadConfig(bytes.NewReader(data))
+}
+
+// loadLayers loads the settings of the sources, the layers are ordered by priority
+func loadLayers(sources []configLayer) (configLayers, error) {
+	var layers configLayers
+	for _, layer := range sources {
+		settings, err := layer.source.Load()
+		if err != nil {
+			return nil, fmt.Errorf("loading config from %s: %w", layer.name, err)
+		}
+		layer.settings = settings
+		layers = layers.with(layer)
+	}
+	return layers, nil
+}
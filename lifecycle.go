@@ -0,0 +1,234 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// Phase of the module lifecycle
+type Phase string
+
+// The phases of the module lifecycle
+const (
+	PhaseInit   Phase = "init"
+	PhaseStart  Phase = "start"
+	PhaseStop   Phase = "stop"
+	PhaseReload Phase = "reload"
+)
+
+// TimeoutError is returned when a module doesn't complete a lifecycle phase before its deadline
+type TimeoutError struct {
+	Module string
+	Phase  Phase
+	// Timeout is set when the timeout of the module expired,
+	// it is zero when the deadline came from the phase or the caller.
+	Timeout time.Duration
+	Err     error
+}
+
+func (e *TimeoutError) Error() string {
+	if e.Timeout > 0 {
+		return fmt.Sprintf("module %s hung in %s: no result after %v", e.Module, e.Phase, e.Timeout)
+	}
+	return fmt.Sprintf("module %s hung in %s: %v", e.Module, e.Phase, e.Err)
+}
+
+// Unwrap returns the context error that ended the phase
+func (e *TimeoutError) Unwrap() error {
+	return e.Err
+}
+
+// phaseContext applies the timeout for a phase across all modules, configured as
+//
+//	lifecycle:
+//	  timeouts:
+//	    start: 2m
+func (d *defaultApplication) phaseContext(ctx context.Context, phase Phase) (context.Context, context.CancelFunc) {
+	if timeout := d.config.GetDuration("lifecycle.timeouts." + string(phase)); timeout > 0 {
+		return context.WithTimeout(ctx, timeout)
+	}
+	return context.WithCancel(ctx)
+}
+
+// moduleTimeout for a phase of a single module, configured as
+//
+//	modules:
+//	  orders:
+//	    timeouts:
+//	      start: 10s
+func (d *defaultApplication) moduleTimeout(name string, phase Phase) time.Duration {
+	return d.config.GetDuration("modules." + name + ".timeouts." + string(phase))
+}
+
+// runPhase calls the lifecycle method of the module for the phase.
+// When the phase has a deadline, the call is abandoned as soon as the deadline passes.
+func (d *defaultApplication) runPhase(ctx context.Context, phase Phase, mod *registration) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("%s %s: %w", phase, mod.name, err)
+	}
+
+	parent := ctx
+	timeout := d.moduleTimeout(mod.name, phase)
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	d.events.publish(Event{Kind: EventPhaseBegin, Module: mod.name, Phase: phase})
+	started := time.Now()
+	err := d.callPhase(parent, ctx, phase, mod, timeout)
+	took := time.Since(started)
+	mod.record(phase, took, err)
+	d.events.publish(Event{Kind: EventPhaseEnd, Module: mod.name, Phase: phase, Duration: took, Err: err})
+	return err
+}
+
+func (d *defaultApplication) callPhase(parent, ctx context.Context, phase Phase, mod *registration, timeout time.Duration) error {
+	if ctx.Done() == nil {
+		// nothing can interrupt this phase, so there is no need to watch it
+		return wrapPhaseError(phase, mod, mod.call(ctx, phase, d))
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- mod.call(ctx, phase, d)
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil && ctx.Err() != nil {
+			return timeoutError(parent, ctx, phase, mod, timeout)
+		}
+		return wrapPhaseError(phase, mod, err)
+	case <-ctx.Done():
+		// the module keeps running in the background, there is no way to interrupt it
+		return timeoutError(parent, ctx, phase, mod, timeout)
+	}
+}
+
+func timeoutError(parent, ctx context.Context, phase Phase, mod *registration, timeout time.Duration) error {
+	err := &TimeoutError{Module: mod.name, Phase: phase, Err: ctx.Err()}
+	if parent.Err() == nil {
+		err.Timeout = timeout
+	}
+	return err
+}
+
+func wrapPhaseError(phase Phase, mod *registration, err error) error {
+	if err == nil {
+		return nil
+	}
+	return fmt.Errorf("%s %s: %w", phase, mod.name, err)
+}
+
+// isEnabled reads modules.<name>.enabled from the config, modules are enabled by default
+func (d *defaultApplication) isEnabled(mod *registration) bool {
+	return enabledIn(d.config, mod)
+}
+
+// enabledIn is true when the config doesn't disable the module
+func enabledIn(cfg *viper.Viper, mod *registration) bool {
+	key := "modules." + mod.name + ".enabled"
+	if !cfg.IsSet(key) {
+		return true
+	}
+	return cfg.GetBool(key)
+}
+
+// checkEnabled updates the last known enabled flag of the module and returns it
+func (d *defaultApplication) checkEnabled(mod *registration) bool {
+	mod.enabled = d.isEnabled(mod)
+	if !mod.enabled {
+		mod.setState(StateDisabled)
+	}
+	return mod.enabled
+}
+
+// reloadModules calls reload on the enabled modules that watch one of the changed keys, and returns their errors.
+// When changed is nil every enabled module reloads. Modules that were enabled in the config since the last reload are initialized and started
+// to match the application, modules that were disabled are stopped.
+func (d *defaultApplication) reloadModules(changed []string) error {
+	d.lifecycleLock.Lock()
+	defer d.lifecycleLock.Unlock()
+	return d.reloadModulesLocked(changed)
+}
+
+// reloadModulesLocked is reloadModules for a caller that holds the lifecycle lock
+func (d *defaultApplication) reloadModulesLocked(changed []string) error {
+	mods, err := d.sortedModules()
+	if err != nil {
+		mods = d.modules
+	}
+	ctx, cancel := d.phaseContext(withChangedKeys(context.Background(), changed), PhaseReload)
+	defer cancel()
+
+	var errs []error
+	for _, mod := range mods {
+		wasEnabled := mod.enabled
+		switch enabled := d.checkEnabled(mod); {
+		case enabled && !wasEnabled:
+			d.enableModule(mod)
+		case !enabled && wasEnabled:
+			d.disableModule(mod)
+		case enabled && mod.interested(changed):
+			if err := d.runPhase(ctx, PhaseReload, mod); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return joinErrors(errs)
+}
+
+// enableModule brings a module that was enabled at runtime up to the phase the application is in
+func (d *defaultApplication) enableModule(mod *registration) {
+	d.Logger().Infof("module %s enabled", mod.name)
+	if !d.initialized {
+		mod.setState(StateRegistered)
+		return
+	}
+	if !mod.isInitialized() {
+		ctx, cancel := d.phaseContext(context.Background(), PhaseInit)
+		defer cancel()
+		if err := d.runPhase(ctx, PhaseInit, mod); err != nil {
+			d.Logger().Errorf("enable module: %v", err)
+			return
+		}
+		d.activate(mod)
+	}
+	if d.started {
+		ctx, cancel := d.phaseContext(context.Background(), PhaseStart)
+		defer cancel()
+		if err := d.runPhase(ctx, PhaseStart, mod); err != nil {
+			d.Logger().Errorf("enable module: %v", err)
+			return
+		}
+		d.activate(mod)
+	}
+}
+
+// disableModule stops a module that was disabled at runtime
+func (d *defaultApplication) disableModule(mod *registration) {
+	d.Logger().Infof("module %s disabled", mod.name)
+	defer mod.setState(StateDisabled)
+	if !containsModule(d.active, mod) {
+		return
+	}
+
+	var remaining []*registration
+	for _, act := range d.active {
+		if act != mod {
+			remaining = append(remaining, act)
+		}
+	}
+	d.active = remaining
+
+	ctx, cancel := d.phaseContext(context.Background(), PhaseStop)
+	defer cancel()
+	if err := d.runPhase(ctx, PhaseStop, mod); err != nil {
+		d.Logger().Errorf("disable module: %v", err)
+	}
+}
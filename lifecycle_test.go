@@ -0,0 +1,159 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func hangingModule(name string, phase Phase) Module {
+	hang := func(ctx context.Context, _ Application) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	block := func(_ Application) error {
+		time.Sleep(time.Second)
+		return nil
+	}
+
+	callbacks := []LifecycleCallback{Name(name)}
+	switch phase {
+	case PhaseInit:
+		callbacks = append(callbacks, InitContext(hang))
+	case PhaseStart:
+		callbacks = append(callbacks, Start(block))
+	case PhaseStop:
+		callbacks = append(callbacks, StopContext(hang))
+	}
+	return MakeModule(callbacks...)
+}
+
+func TestLifecycle_ModuleTimeout(t *testing.T) {
+	app, err := New("")
+	if assert.NoError(t, err) {
+		app.Config().Set("modules.db.timeouts.init", "10ms")
+		app.Add(hangingModule("db", PhaseInit))
+
+		err := app.Init()
+		if assert.Error(t, err) {
+			assert.EqualError(t, err, "module db hung in init: no result after 10ms")
+			assert.True(t, errors.Is(err, context.DeadlineExceeded))
+			var te *TimeoutError
+			if assert.True(t, errors.As(err, &te)) {
+				assert.Equal(t, "db", te.Module)
+				assert.Equal(t, PhaseInit, te.Phase)
+			}
+		}
+	}
+}
+
+func TestLifecycle_PlainModuleTimeout(t *testing.T) {
+	app, err := New("")
+	if assert.NoError(t, err) {
+		app.Config().Set("lifecycle.timeouts.start", "10ms")
+		app.Add(hangingModule("db", PhaseStart))
+
+		started := time.Now()
+		err := app.Start()
+		assert.True(t, time.Since(started) < time.Second)
+		assert.EqualError(t, err, "module db hung in start: context deadline exceeded")
+	}
+}
+
+func TestLifecycle_CallerContext(t *testing.T) {
+	var stopped []string
+	stopper := func(name string) Module {
+		return MakeModule(Name(name), Stop(func(_ Application) error {
+			stopped = append(stopped, name)
+			return nil
+		}))
+	}
+
+	app, err := New("")
+	if assert.NoError(t, err) {
+		app.Add(stopper("first"), hangingModule("db", PhaseStop), stopper("last"))
+		assert.NoError(t, app.Init())
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		err := app.StopContext(ctx)
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), "module db hung in stop: context deadline exceeded")
+			assert.Contains(t, err.Error(), "stop first: context deadline exceeded")
+		}
+		assert.Equal(t, []string{"last"}, stopped)
+	}
+}
+
+func TestLifecycle_ContextCancelled(t *testing.T) {
+	var initCount int
+	app, err := New("")
+	if assert.NoError(t, err) {
+		app.Add(MakeModule(Name("db"), Init(func(_ Application) error {
+			initCount++
+			return nil
+		})))
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		assert.EqualError(t, app.InitContext(ctx), "init db: context canceled")
+		assert.Equal(t, 0, initCount)
+	}
+}
+
+func TestLifecycle_EnableDisable(t *testing.T) {
+	var events []string
+	record := func(name string) Module {
+		return MakeModule(
+			Name(name),
+			Init(func(_ Application) error {
+				events = append(events, "init "+name)
+				return nil
+			}),
+			Start(func(_ Application) error {
+				events = append(events, "start "+name)
+				return nil
+			}),
+			Reload(func(_ Application) error {
+				events = append(events, "reload "+name)
+				return nil
+			}),
+			Stop(func(_ Application) error {
+				events = append(events, "stop "+name)
+				return nil
+			}),
+		)
+	}
+
+	appi, err := New("")
+	if assert.NoError(t, err) {
+		app := appi.(*defaultApplication)
+		app.Config().Set("modules.optional.enabled", false)
+		app.Add(record("always"), record("optional"))
+
+		assert.NoError(t, app.Init())
+		assert.NoError(t, app.Start())
+		assert.Equal(t, []string{"init always", "start always"}, events)
+
+		events = nil
+		app.Config().Set("modules.optional.enabled", true)
+		app.reloadModules(nil)
+		assert.Equal(t, []string{"reload always", "init optional", "start optional"}, events)
+
+		events = nil
+		app.reloadModules(nil)
+		assert.Equal(t, []string{"reload always", "reload optional"}, events)
+
+		events = nil
+		app.Config().Set("modules.optional.enabled", "false")
+		app.reloadModules(nil)
+		assert.Equal(t, []string{"reload always", "stop optional"}, events)
+
+		events = nil
+		assert.NoError(t, app.Stop())
+		assert.Equal(t, []string{"stop always"}, events)
+	}
+}
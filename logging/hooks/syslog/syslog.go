@@ -91,6 +91,15 @@ func init() {
 		if err != nil {
 			panic(err)
 		}
-		return slg
+		return hook{slg}
 	})
 }
+
+// hook closes the connection to syslog when the registry is closed
+type hook struct {
+	*lrs.SyslogHook
+}
+
+func (h hook) Close() error {
+	return h.Writer.Close()
+}
@@ -0,0 +1,120 @@
+package logging
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// LevelOverride is a level that was set at runtime, it replaces the configured level of a logger until it expires
+type LevelOverride struct {
+	Level logrus.Level
+	// Expires is zero when the override stays until it is reset
+	Expires time.Time
+}
+
+type levelOverride struct {
+	LevelOverride
+	timer *time.Timer
+}
+
+func loggerLevel(logger *logrus.Logger) logrus.Level {
+	return logrus.Level(atomic.LoadUint32((*uint32)(&logger.Level)))
+}
+
+// SetLevel overrides the level of a logger by its path, eg. root.orders.
+// When the ttl is larger than 0 the logger returns to its configured level once the ttl passed.
+// Overrides survive reloading the config.
+//
+// The children that share the config of the logger follow its level, unless they have an override of their own.
+// The level of the parent doesn't change.
+func (r *Registry) SetLevel(name string, level logrus.Level, ttl time.Duration) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	key := strings.ToLower(name)
+	l, ok := r.store[key]
+	if !ok {
+		return fmt.Errorf("unknown logger %q", key)
+	}
+	if _, ok := l.(*defaultLogger); !ok {
+		return fmt.Errorf("logger %q does not support changing its level", key)
+	}
+
+	r.clearOverride(key)
+	ovr := &levelOverride{LevelOverride: LevelOverride{Level: level}}
+	if ttl > 0 {
+		ovr.Expires = time.Now().Add(ttl)
+		ovr.timer = time.AfterFunc(ttl, func() { r.expire(key, ovr) })
+	}
+	r.overrides[key] = ovr
+	r.applyOverrides()
+	return nil
+}
+
+// ResetLevel removes the override of a logger, the logger returns to its configured level
+func (r *Registry) ResetLevel(name string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.resetLevel(strings.ToLower(name))
+}
+
+// LevelOverrides returns the overrides that are in effect, by logger path
+func (r *Registry) LevelOverrides() map[string]LevelOverride {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	res := make(map[string]LevelOverride, len(r.overrides))
+	for k, v := range r.overrides {
+		res[k] = v.LevelOverride
+	}
+	return res
+}
+
+func (r *Registry) expire(key string, ovr *levelOverride) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	// the override might have been replaced since the timer started
+	if r.overrides[key] == ovr {
+		r.resetLevel(key)
+	}
+}
+
+func (r *Registry) resetLevel(key string) {
+	if _, ok := r.overrides[key]; !ok {
+		return
+	}
+	r.clearOverride(key)
+	r.applyOverrides()
+}
+
+func (r *Registry) clearOverride(key string) {
+	if ovr, ok := r.overrides[key]; ok {
+		if ovr.timer != nil {
+			ovr.timer.Stop()
+		}
+		delete(r.overrides, key)
+	}
+}
+
+// applyOverrides sets the level of every logger to its override, or to the override of the parent it follows,
+// or to its configured level. It runs again after the loggers were configured.
+func (r *Registry) applyOverrides() {
+	for _, logger := range r.store {
+		if dl, ok := logger.(*defaultLogger); ok {
+			dl.Logger.SetLevel(r.levelOf(dl))
+		}
+	}
+}
+
+func (r *Registry) levelOf(logger *defaultLogger) logrus.Level {
+	for l := logger; l != nil; l = l.parent {
+		if ovr, ok := r.overrides[strings.Join(l.path, ".")]; ok {
+			return ovr.Level
+		}
+	}
+	return parseLevel(logger.config.GetString("level"))
+}
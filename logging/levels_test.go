@@ -0,0 +1,103 @@
+package logging
+
+import (
+	"bytes"
+	"io/ioutil"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLogging_SetLevel(t *testing.T) {
+	v := viper.New()
+	v.SetConfigType("yaml")
+	if assert.NoError(t, v.ReadConfig(bytes.NewBuffer(rc4))) {
+		reg := NewRegistry(v, nil)
+		root := reg.Root().(*defaultLogger)
+		child1 := root.New("child1", nil).(*defaultLogger)
+		child2 := root.New("child2", nil).(*defaultLogger)
+		child2child := child2.New("child2child", nil).(*defaultLogger)
+
+		assert.EqualError(t, reg.SetLevel("root.unknown", logrus.InfoLevel, 0), `unknown logger "root.unknown"`)
+
+		// child1 has a config of its own
+		assert.NoError(t, reg.SetLevel("root.child1", logrus.DebugLevel, 0))
+		assert.Equal(t, logrus.DebugLevel, child1.Logger.Level)
+		assert.Equal(t, logrus.DebugLevel, root.Logger.Level)
+
+		// child2 shares the logger of root, it gets a copy so root keeps its level
+		assert.NoError(t, reg.SetLevel("Root.Child2", logrus.ErrorLevel, 0))
+		assert.Equal(t, logrus.ErrorLevel, child2.Logger.Level)
+		assert.Equal(t, logrus.ErrorLevel, child2child.Logger.Level)
+		assert.Equal(t, logrus.DebugLevel, root.Logger.Level)
+		assert.Equal(t, root.Logger.Formatter, child2.Logger.Formatter)
+
+		assert.Equal(t, map[string]LevelOverride{
+			"root.child1": {Level: logrus.DebugLevel},
+			"root.child2": {Level: logrus.ErrorLevel},
+		}, reg.LevelOverrides())
+
+		// overrides survive a reload
+		if assert.NoError(t, v.ReadConfig(bytes.NewBuffer(rc5))) {
+			reg.Reload()
+			assert.Equal(t, logrus.WarnLevel, root.Logger.Level)
+			assert.Equal(t, logrus.DebugLevel, child1.Logger.Level)
+			assert.Equal(t, logrus.ErrorLevel, child2.Logger.Level)
+		}
+
+		reg.ResetLevel("root.child1")
+		reg.ResetLevel("root.child2")
+		assert.Equal(t, logrus.ErrorLevel, child1.Logger.Level)
+		assert.Equal(t, logrus.WarnLevel, child2.Logger.Level)
+		assert.Empty(t, reg.LevelOverrides())
+	}
+}
+
+func TestLogging_SetLevelTTL(t *testing.T) {
+	reg := NewRegistry(nil, nil)
+	root := reg.Root().(*defaultLogger)
+	configured := loggerLevel(root.Logger)
+
+	assert.NoError(t, reg.SetLevel("root", logrus.DebugLevel, 20*time.Millisecond))
+	assert.Equal(t, logrus.DebugLevel, reg.Levels()["root"])
+	ovr := reg.LevelOverrides()["root"]
+	assert.False(t, ovr.Expires.IsZero())
+
+	assert.Eventually(t, func() bool {
+		return reg.Levels()["root"] == configured
+	}, time.Second, 5*time.Millisecond)
+	assert.Empty(t, reg.LevelOverrides())
+}
+
+func TestLogging_SetLevelWhileLogging(t *testing.T) {
+	for i := 0; i < 20; i++ {
+		reg := NewRegistry(nil, nil)
+		root := reg.Root().(*defaultLogger)
+		root.Logger.SetOutput(ioutil.Discard)
+		configured := loggerLevel(root.Logger)
+		orders := root.New("orders", nil).(*defaultLogger)
+
+		var wg sync.WaitGroup
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				orders.Info("placing order")
+			}
+		}()
+		assert.NoError(t, reg.SetLevel("root.orders", logrus.DebugLevel, 0))
+		wg.Wait()
+
+		assert.Equal(t, logrus.DebugLevel, orders.Logger.Level)
+		assert.Equal(t, configured, root.Logger.Level)
+
+		// without an override of its own orders follows the override of root
+		reg.ResetLevel("root.orders")
+		assert.NoError(t, reg.SetLevel("root", logrus.WarnLevel, 0))
+		assert.Equal(t, logrus.WarnLevel, orders.Logger.Level)
+	}
+}
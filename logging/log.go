@@ -56,8 +56,8 @@ var loggerLock = new(sync.Mutex)
 func configureLogger(logger *logrus.Logger, fields logrus.Fields, cfg *viper.Viper) {
 	loggerLock.Lock()
 	defer loggerLock.Unlock()
-	logger.Level = parseLevel(cfg.GetString("level"))
-	logger.Formatter = parseFormatter(cfg.GetString("format"), cfg)
+	logger.SetLevel(parseLevel(cfg.GetString("level")))
+	logger.SetFormatter(parseFormatter(cfg.GetString("format"), cfg))
 
 	// writer config can be a string key or a full fledged config.
 	var wcfg *viper.Viper
@@ -71,12 +71,31 @@ func configureLogger(logger *logrus.Logger, fields logrus.Fields, cfg *viper.Vip
 			wcfg = cfg.Sub("writer")
 		}
 	}
-	logger.Out = parseWriter(wcfg)
+	logger.SetOutput(parseWriter(wcfg))
 
-	logger.Hooks = make(logrus.LevelHooks, len(logrus.AllLevels))
+	// the setters take the lock of the logger, it might be logging while it gets reconfigured
+	hooks := make(logrus.LevelHooks, len(logrus.AllLevels))
 	for _, hook := range parseHooks(cfg) {
-		logger.Hooks.Add(hook)
+		hooks.Add(hook)
 	}
+	logger.ReplaceHooks(hooks)
+}
+
+// followLogger creates a logger that writes like the parent, with the same output, formatter, hooks and level.
+// The logger is not shared, so its level can change without changing the level of the parent.
+func followLogger(parent *logrus.Logger) *logrus.Logger {
+	loggerLock.Lock()
+	defer loggerLock.Unlock()
+
+	logger := logrus.New()
+	logger.Out = parent.Out
+	logger.Formatter = parent.Formatter
+	logger.Hooks = make(logrus.LevelHooks, len(parent.Hooks))
+	for lvl, hooks := range parent.Hooks {
+		logger.Hooks[lvl] = append([]logrus.Hook(nil), hooks...)
+	}
+	logger.SetLevel(loggerLevel(parent))
+	return logger
 }
 
 func newNamedLogger(name string, fields logrus.Fields, cfg *viper.Viper, parent *defaultLogger) *defaultLogger {
@@ -115,6 +134,8 @@ type defaultLogger struct {
 	config *viper.Viper
 	path   []string
 	reg    *Registry
+	// parent is set when the logger shares the config of its parent, it follows the level overrides of the parent
+	parent *defaultLogger
 }
 
 func (d *defaultLogger) New(name string, fields logrus.Fields) Logger {
@@ -136,14 +157,15 @@ func (d *defaultLogger) New(name string, fields logrus.Fields) Logger {
 		return l
 	}
 
-	// Share the logger with the parent, same config
+	// Same config as the parent, so it follows the parent with a logger of its own
 	l := &defaultLogger{
 		Entry: logrus.Entry{
-			Logger: d.Entry.Logger,
+			Logger: followLogger(d.Entry.Logger),
 			Data:   data,
 		},
 		config: d.config,
 		path:   append(d.path, nme),
+		parent: d,
 	}
 	l.reg = d.reg
 	d.reg.Register(pth, l)
@@ -2,6 +2,7 @@ package logging
 
 import (
 	"io"
+	"os"
 	"sort"
 	"strings"
 	"sync"
@@ -30,9 +31,10 @@ func init() {
 
 // LoggerRegistry represents a registry for known loggers
 type Registry struct {
-	config *viper.Viper
-	store  map[string]Logger
-	lock   *sync.Mutex
+	config    *viper.Viper
+	store     map[string]Logger
+	overrides map[string]*levelOverride
+	lock      *sync.Mutex
 }
 
 // NewRegistry creates a new logger registry
@@ -41,10 +43,7 @@ func NewRegistry(cfg *viper.Viper, context logrus.Fields) *Registry {
 		cfg = viper.New()
 	}
 
-	c := cfg
-	if c.IsSet("logging") {
-		c = cfg.Sub("logging")
-	}
+	c := loggingConfig(cfg)
 
 	var keys []string
 	for _, kn := range c.AllKeys() {
@@ -56,9 +55,10 @@ func NewRegistry(cfg *viper.Viper, context logrus.Fields) *Registry {
 
 	store := make(map[string]Logger, len(keys))
 	reg := &Registry{
-		store:  store,
-		config: c,
-		lock:   new(sync.Mutex),
+		store:     store,
+		config:    c,
+		overrides: make(map[string]*levelOverride),
+		lock:      new(sync.Mutex),
 	}
 
 	for _, k := range keys {
@@ -136,6 +136,78 @@ func (r *Registry) Writer() *io.PipeWriter {
 	return r.Root().(*defaultLogger).Logger.Writer()
 }
 
+// Close stops the timers of the level overrides and closes the hooks and writers of the loggers that can be closed,
+// stdout and stderr are left open. The first error is returned, the remaining hooks and writers are still closed.
+func (r *Registry) Close() error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	for key := range r.overrides {
+		r.clearOverride(key)
+	}
+
+	var first error
+	closed := make(map[io.Closer]struct{})
+	closeOnce := func(c io.Closer) {
+		if _, ok := closed[c]; ok {
+			return
+		}
+		closed[c] = struct{}{}
+		if err := c.Close(); err != nil && first == nil {
+			first = err
+		}
+	}
+	for _, logger := range r.store {
+		dl, ok := logger.(*defaultLogger)
+		if !ok {
+			continue
+		}
+		for _, hooks := range dl.Logger.Hooks {
+			for _, hook := range hooks {
+				if c, ok := hook.(io.Closer); ok {
+					closeOnce(c)
+				}
+			}
+		}
+		if c, ok := dl.Logger.Out.(io.Closer); ok && dl.Logger.Out != os.Stdout && dl.Logger.Out != os.Stderr {
+			closeOnce(c)
+		}
+	}
+	return first
+}
+
+// Levels returns the level of every known logger, by name
+func (r *Registry) Levels() map[string]logrus.Level {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	levels := make(map[string]logrus.Level, len(r.store))
+	for name, logger := range r.store {
+		if dl, ok := logger.(*defaultLogger); ok {
+			levels[name] = loggerLevel(dl.Logger)
+		}
+	}
+	return levels
+}
+
+// loggingConfig returns the logging section of the config, or the config itself when it has none
+func loggingConfig(cfg *viper.Viper) *viper.Viper {
+	if cfg.IsSet("logging") {
+		return cfg.Sub("logging")
+	}
+	return cfg
+}
+
+// Configure replaces the config of the registry, and reloads all the loggers with it.
+// The registry keeps the logging section of the config it was created with,
+// that section doesn't change when the config is read again.
+func (r *Registry) Configure(cfg *viper.Viper) {
+	r.lock.Lock()
+	r.config = loggingConfig(cfg)
+	r.lock.Unlock()
+	r.Reload()
+}
+
 // Reload all the loggers with the new config
 func (r *Registry) Reload() {
 	r.lock.Lock()
@@ -166,6 +238,7 @@ func (r *Registry) Reload() {
 			logger.Configure(cfg)
 		}
 	}
+	r.applyOverrides()
 }
 
 func findLongestMatchingPath(path string, cfg *viper.Viper) *viper.Viper {
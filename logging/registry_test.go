@@ -2,7 +2,9 @@ package logging
 
 import (
 	"bytes"
+	"io"
 	"testing"
+	"time"
 
 	"github.com/kr/pretty"
 	"github.com/sirupsen/logrus"
@@ -202,8 +204,10 @@ func TestLogging_Reload(t *testing.T) {
 		assert.IsType(&logrus.JSONFormatter{}, root.Logger.Formatter)
 		assert.Equal(logrus.InfoLevel, alerts.Logger.Level)
 		assert.IsType(&logrus.JSONFormatter{}, alerts.Logger.Formatter)
-		assert.Equal(root.Logger, child2.Logger)
-		assert.Equal(root.Logger, child2child.Logger)
+		assert.Equal(root.Logger.Level, child2.Logger.Level)
+		assert.Equal(root.Logger.Formatter, child2.Logger.Formatter)
+		assert.Equal(root.Logger.Level, child2child.Logger.Level)
+		assert.Equal(root.Logger.Formatter, child2child.Logger.Formatter)
 		assert.Equal(logrus.WarnLevel, child1.Logger.Level)
 		assert.IsType(&logrus.TextFormatter{}, child1.Logger.Formatter)
 		assert.Equal(logrus.ErrorLevel, child1child.Logger.Level)
@@ -218,8 +222,10 @@ func TestLogging_Reload(t *testing.T) {
 			assert.IsType(&logrus.TextFormatter{}, root.Logger.Formatter)
 			assert.Equal(logrus.ErrorLevel, alerts.Logger.Level)
 			assert.IsType(&logrus.TextFormatter{}, alerts.Logger.Formatter)
-			assert.Equal(root.Logger, child2.Logger)
-			assert.Equal(root.Logger, child2child.Logger)
+			assert.Equal(root.Logger.Level, child2.Logger.Level)
+			assert.IsType(&logrus.TextFormatter{}, child2.Logger.Formatter)
+			assert.Equal(root.Logger.Level, child2child.Logger.Level)
+			assert.IsType(&logrus.TextFormatter{}, child2child.Logger.Formatter)
 			assert.Equal(logrus.ErrorLevel, child1.Logger.Level)
 			assert.IsType(&logrus.JSONFormatter{}, child1.Logger.Formatter)
 			assert.Equal(logrus.InfoLevel, child1child.Logger.Level)
@@ -264,3 +270,55 @@ alerts:
 
 	assert.Nil(t, findLongestMatchingPath("not-there", v1))
 }
+
+func TestLogging_RegistryConfigure(t *testing.T) {
+	assert := assert.New(t)
+	v1 := viper.New()
+	v1.SetConfigType("yaml")
+	if assert.NoError(v1.ReadConfig(bytes.NewBuffer(rc1))) {
+		reg := NewRegistry(v1, nil)
+		root := reg.Root().(*defaultLogger)
+		assert.Equal(logrus.DebugLevel, root.Logger.Level)
+
+		// reading the config again replaces the logging section, the registry only sees it through Configure
+		if assert.NoError(v1.ReadConfig(bytes.NewBufferString("logging:\n  root:\n    level: warn\n"))) {
+			reg.Reload()
+			assert.Equal(logrus.DebugLevel, root.Logger.Level)
+			reg.Configure(v1)
+			assert.Equal(logrus.WarnLevel, root.Logger.Level)
+		}
+	}
+}
+
+type closeRecorder struct {
+	closed int
+}
+
+func (c *closeRecorder) Write(p []byte) (int, error) { return len(p), nil }
+func (c *closeRecorder) Levels() []logrus.Level      { return logrus.AllLevels }
+func (c *closeRecorder) Fire(_ *logrus.Entry) error  { return nil }
+func (c *closeRecorder) Close() error {
+	c.closed++
+	return nil
+}
+
+func TestLogging_RegistryClose(t *testing.T) {
+	writer, hook := new(closeRecorder), new(closeRecorder)
+	RegisterWriter("close-recorder", func(_ *viper.Viper) io.Writer { return writer })
+	RegisterHook("close-recorder", func(_ *viper.Viper) logrus.Hook { return hook })
+
+	v := viper.New()
+	v.SetConfigType("yaml")
+	cfg := "logging:\n  root:\n    writer: close-recorder\n    hooks:\n      - name: close-recorder\n    child:\n      level: debug\n"
+	if assert.NoError(t, v.ReadConfig(bytes.NewBufferString(cfg))) {
+		reg := NewRegistry(v, nil)
+		reg.Root().New("child", nil)
+		assert.NoError(t, reg.SetLevel("root", logrus.DebugLevel, time.Hour))
+
+		assert.NoError(t, reg.Close())
+		// the writer and the hook are shared by the loggers, they are closed once
+		assert.Equal(t, 1, writer.closed)
+		assert.Equal(t, 1, hook.closed)
+		assert.Empty(t, reg.LevelOverrides())
+	}
+}
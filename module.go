@@ -1,10 +1,25 @@
 package app
 
+import (
+	"context"
+	"runtime"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
 // LifecycleCallback function definition
 type LifecycleCallback interface {
 	Call(Application) error
 }
 
+// ContextCallback is a lifecycle callback that receives the context of the phase it runs in.
+// The context is cancelled when the phase times out or the caller gives up.
+type ContextCallback interface {
+	LifecycleCallback
+	CallContext(context.Context, Application) error
+}
+
 // Init is an initializer for an initalization function
 type Init func(Application) error
 
@@ -13,6 +28,24 @@ func (fn Init) Call(app Application) error {
 	return fn(app)
 }
 
+// CallContext implements the context callback interface, the context is ignored
+func (fn Init) CallContext(_ context.Context, app Application) error {
+	return fn(app)
+}
+
+// InitContext is an initializer for an initialization function that observes the context of the init phase
+type InitContext func(context.Context, Application) error
+
+// Call implements the callback interface with a background context
+func (fn InitContext) Call(app Application) error {
+	return fn(context.Background(), app)
+}
+
+// CallContext implements the context callback interface
+func (fn InitContext) CallContext(ctx context.Context, app Application) error {
+	return fn(ctx, app)
+}
+
 // Start is an initializer for a start function
 type Start func(Application) error
 
@@ -21,6 +54,24 @@ func (fn Start) Call(app Application) error {
 	return fn(app)
 }
 
+// CallContext implements the context callback interface, the context is ignored
+func (fn Start) CallContext(_ context.Context, app Application) error {
+	return fn(app)
+}
+
+// StartContext is an initializer for a start function that observes the context of the start phase
+type StartContext func(context.Context, Application) error
+
+// Call implements the callback interface with a background context
+func (fn StartContext) Call(app Application) error {
+	return fn(context.Background(), app)
+}
+
+// CallContext implements the context callback interface
+func (fn StartContext) CallContext(ctx context.Context, app Application) error {
+	return fn(ctx, app)
+}
+
 // Stop is an initializer for a stop function
 type Stop func(Application) error
 
@@ -29,6 +80,24 @@ func (fn Stop) Call(app Application) error {
 	return fn(app)
 }
 
+// CallContext implements the context callback interface, the context is ignored
+func (fn Stop) CallContext(_ context.Context, app Application) error {
+	return fn(app)
+}
+
+// StopContext is an initializer for a stop function that observes the context of the stop phase
+type StopContext func(context.Context, Application) error
+
+// Call implements the callback interface with a background context
+func (fn StopContext) Call(app Application) error {
+	return fn(context.Background(), app)
+}
+
+// CallContext implements the context callback interface
+func (fn StopContext) CallContext(ctx context.Context, app Application) error {
+	return fn(ctx, app)
+}
+
 // Reload is an initalizater for a reload function
 type Reload func(Application) error
 
@@ -37,6 +106,60 @@ func (fn Reload) Call(app Application) error {
 	return fn(app)
 }
 
+// CallContext implements the context callback interface, the context is ignored
+func (fn Reload) CallContext(_ context.Context, app Application) error {
+	return fn(app)
+}
+
+// ReloadContext is an initializer for a reload function that observes the context of the reload phase
+type ReloadContext func(context.Context, Application) error
+
+// Call implements the callback interface with a background context
+func (fn ReloadContext) Call(app Application) error {
+	return fn(context.Background(), app)
+}
+
+// CallContext implements the context callback interface
+func (fn ReloadContext) CallContext(ctx context.Context, app Application) error {
+	return fn(ctx, app)
+}
+
+// Name declares the name of a module made with MakeModule
+func Name(name string) LifecycleCallback {
+	return moduleName(name)
+}
+
+type moduleName string
+
+// Call implements the callback interface, a name has no behavior
+func (moduleName) Call(_ Application) error {
+	return nil
+}
+
+// Provides declares the keys a module made with MakeModule sets in the application
+func Provides(keys ...Key) LifecycleCallback {
+	return provides(keys)
+}
+
+type provides []Key
+
+// Call implements the callback interface, a declaration has no behavior
+func (provides) Call(_ Application) error {
+	return nil
+}
+
+// Requires declares the keys a module made with MakeModule needs to be present in the application
+func Requires(keys ...Key) LifecycleCallback {
+	return requires(keys)
+}
+
+type requires []Key
+
+// Call implements the callback interface, a declaration has no behavior
+func (requires) Call(_ Application) error {
+	return nil
+}
+
 // A Module is a component that has a specific lifecycle
 type Module interface {
 	Init(Application) error
@@ -45,76 +168,251 @@ type Module interface {
 	Reload(Application) error
 }
 
+// A ContextModule is a component with the same lifecycle as a Module,
+// but every phase receives a context that is cancelled when the phase times out.
+// Modules made with MakeModule implement both interfaces.
+type ContextModule interface {
+	InitContext(context.Context, Application) error
+	StartContext(context.Context, Application) error
+	StopContext(context.Context, Application) error
+	ReloadContext(context.Context, Application) error
+}
+
+// Named is implemented by modules that know their own name.
+// Modules without a name are named after the last element of their package path.
+type Named interface {
+	Name() string
+}
+
+// Dependent is implemented by modules that declare the keys they provide and require.
+// The application uses these declarations to initialize providers before the modules that require their keys.
+type Dependent interface {
+	Provides() []Key
+	Requires() []Key
+}
+
 // MakeModule by passing the callback functions.
 // You can pass multiple callback functions of the same type if you want
 func MakeModule(callbacks ...LifecycleCallback) Module {
 	var (
-		init   []Init
-		start  []Start
-		reload []Reload
-		stop   []Stop
+		name   string
+		init   []ContextCallback
+		start  []ContextCallback
+		reload []ContextCallback
+		stop   []ContextCallback
+		prov   []Key
+		req    []Key
+		health []HealthCheck
+		ready  []ReadinessCheck
+		watch  []string
+		valid  []Validate
 	)
 
 	for _, callback := range callbacks {
 		switch cb := callback.(type) {
-		case Init:
-			init = append(init, cb)
-		case Start:
-			start = append(start, cb)
-		case Stop:
-			stop = append(stop, cb)
-		case Reload:
-			reload = append(reload, cb)
+		case Init, InitContext:
+			init = append(init, cb.(ContextCallback))
+		case Start, StartContext:
+			start = append(start, cb.(ContextCallback))
+		case Stop, StopContext:
+			stop = append(stop, cb.(ContextCallback))
+		case Reload, ReloadContext:
+			reload = append(reload, cb.(ContextCallback))
+		case moduleName:
+			name = string(cb)
+		case provides:
+			prov = append(prov, cb...)
+		case requires:
+			req = append(req, cb...)
+		case HealthCheck:
+			health = append(health, cb)
+		case ReadinessCheck:
+			ready = append(ready, cb)
+		case watches:
+			watch = append(watch, cb...)
+		case Validate:
+			valid = append(valid, cb)
 		}
 	}
 
 	return &dynamicModule{
-		init:   init,
-		start:  start,
-		reload: reload,
-		stop:   stop,
+		name:     name,
+		pkg:      callerPackage(2),
+		init:     init,
+		start:    start,
+		reload:   reload,
+		stop:     stop,
+		provides: prov,
+		requires: req,
+		health:   health,
+		ready:    ready,
+		watches:  watch,
+		validate: valid,
+	}
+}
+
+// callerPackage returns the short package name of the function skip frames up the stack
+func callerPackage(skip int) string {
+	pc, _, _, ok := runtime.Caller(skip)
+	if !ok {
+		return ""
 	}
+	fn := runtime.FuncForPC(pc)
+	if fn == nil {
+		return ""
+	}
+	return packageName(fn.Name())
+}
+
+// packageName extracts the last element of the package path from a qualified name,
+// eg. github.com/some/orders.init.func1 becomes orders
+func packageName(qualified string) string {
+	name := qualified
+	if idx := strings.LastIndex(name, "/"); idx >= 0 {
+		name = name[idx+1:]
+	}
+	if idx := strings.Index(name, "."); idx >= 0 {
+		name = name[:idx]
+	}
+	return name
 }
 
 type dynamicModule struct {
-	init   []Init
-	start  []Start
-	stop   []Stop
-	reload []Reload
+	name     string
+	pkg      string
+	init     []ContextCallback
+	start    []ContextCallback
+	stop     []ContextCallback
+	reload   []ContextCallback
+	provides []Key
+	requires []Key
+	health   []HealthCheck
+	ready    []ReadinessCheck
+	watches  []string
+	validate []Validate
+}
+
+func (d *dynamicModule) Name() string {
+	return d.name
+}
+
+func (d *dynamicModule) defaultName() string {
+	return d.pkg
+}
+
+func (d *dynamicModule) Provides() []Key {
+	return d.provides
+}
+
+func (d *dynamicModule) Requires() []Key {
+	return d.requires
+}
+
+func (d *dynamicModule) Watches() []string {
+	return d.watches
 }
 
 func (d *dynamicModule) Init(app Application) error {
-	for _, cb := range d.init {
-		if err := cb.Call(app); err != nil {
+	return d.InitContext(context.Background(), app)
+}
+
+func (d *dynamicModule) InitContext(ctx context.Context, app Application) error {
+	return runCallbacks(ctx, app, d.init)
+}
+
+func (d *dynamicModule) Start(app Application) error {
+	return d.StartContext(context.Background(), app)
+}
+
+func (d *dynamicModule) StartContext(ctx context.Context, app Application) error {
+	return runCallbacks(ctx, app, d.start)
+}
+
+func (d *dynamicModule) Stop(app Application) error {
+	return d.StopContext(context.Background(), app)
+}
+
+func (d *dynamicModule) StopContext(ctx context.Context, app Application) error {
+	return runCallbacks(ctx, app, d.stop)
+}
+
+func (d *dynamicModule) Reload(app Application) error {
+	return d.ReloadContext(context.Background(), app)
+}
+
+func (d *dynamicModule) ReloadContext(ctx context.Context, app Application) error {
+	return runCallbacks(ctx, app, d.reload)
+}
+
+// CheckHealth runs the health checks of the module, stops at the first error
+func (d *dynamicModule) CheckHealth(ctx context.Context, app Application) error {
+	for _, check := range d.health {
+		if err := check(ctx, app); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
-func (d *dynamicModule) Start(app Application) error {
-	for _, cb := range d.start {
-		if err := cb.Call(app); err != nil {
+// CheckReady runs the readiness checks of the module, stops at the first error
+func (d *dynamicModule) CheckReady(ctx context.Context, app Application) error {
+	for _, check := range d.ready {
+		if err := check(ctx, app); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
-func (d *dynamicModule) Stop(app Application) error {
-	for _, cb := range d.stop {
-		if err := cb.Call(app); err != nil {
-			return err
+// Validate runs the validations of the module, all errors are returned
+func (d *dynamicModule) Validate(cfg *viper.Viper) error {
+	var errs []error
+	for _, validate := range d.validate {
+		if err := validate(cfg); err != nil {
+			errs = append(errs, err)
 		}
 	}
-	return nil
+	return joinErrors(errs)
 }
 
-func (d *dynamicModule) Reload(app Application) error {
-	for _, cb := range d.reload {
-		if err := cb.Call(app); err != nil {
+// runCallbacks in order, stops at the first error or when the context is done
+func runCallbacks(ctx context.Context, app Application, callbacks []ContextCallback) error {
+	for _, cb := range callbacks {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := cb.CallContext(ctx, app); err != nil {
 			return err
 		}
 	}
 	return nil
 }
+
+// moduleAdapter turns a Module into a ContextModule, the context is ignored
+type moduleAdapter struct {
+	Module
+}
+
+func (m moduleAdapter) InitContext(_ context.Context, app Application) error {
+	return m.Init(app)
+}
+
+func (m moduleAdapter) StartContext(_ context.Context, app Application) error {
+	return m.Start(app)
+}
+
+func (m moduleAdapter) StopContext(_ context.Context, app Application) error {
+	return m.Stop(app)
+}
+
+func (m moduleAdapter) ReloadContext(_ context.Context, app Application) error {
+	return m.Reload(app)
+}
+
+// asContextModule returns the module itself when it supports contexts, an adapter otherwise
+func asContextModule(mod Module) ContextModule {
+	if cm, ok := mod.(ContextModule); ok {
+		return cm
+	}
+	return moduleAdapter{mod}
+}
@@ -1,6 +1,7 @@
 package app
 
 import (
+	"context"
 	"errors"
 	"testing"
 
@@ -223,17 +224,99 @@ func TestApplication_AddModuleError(t *testing.T) {
 		app.Add(successMod, failMod)
 		assert.Len(t, app.(*defaultApplication).modules, 2)
 
+		// the failed init stops the module that was initialized
 		if assert.Error(t, app.Init()) {
 			assert.Equal(t, initCount, 3)
+			assert.Equal(t, stopCount, 5)
 		}
 
+		// the failed start stops the module that was started
 		if assert.Error(t, app.Start()) {
 			assert.Equal(t, startCount, 5)
+			assert.Equal(t, stopCount, 10)
+		}
+
+		// nothing left to stop
+		if assert.NoError(t, app.Stop()) {
+			assert.Equal(t, stopCount, 10)
 		}
+	}
+}
+
+func TestApplication_StopReverseOrder(t *testing.T) {
+	var events []string
+	record := func(name string) Module {
+		return MakeModule(
+			Name(name),
+			Init(func(_ Application) error {
+				events = append(events, "init "+name)
+				return nil
+			}),
+			Start(func(_ Application) error {
+				events = append(events, "start "+name)
+				return nil
+			}),
+			Stop(func(_ Application) error {
+				events = append(events, "stop "+name)
+				if name == "second" {
+					return errors.New("expected")
+				}
+				return nil
+			}),
+		)
+	}
+
+	app, err := New("")
+	if assert.NoError(t, err) {
+		app.Add(record("first"), record("second"), record("third"))
+		assert.NoError(t, app.Init())
+		assert.NoError(t, app.Start())
+		// an error while stopping doesn't prevent the other modules from stopping
+		assert.EqualError(t, app.Stop(), "stop second: expected")
+		assert.Equal(t, []string{
+			"init first", "init second", "init third",
+			"start first", "start second", "start third",
+			"stop third", "stop second", "stop first",
+		}, events)
+	}
+}
 
-		if assert.Error(t, app.Stop()) {
-			assert.Equal(t, stopCount, 7)
+func TestApplication_StartRollback(t *testing.T) {
+	var events []string
+	record := func(name string, failStart, failStop bool) Module {
+		return MakeModule(
+			Name(name),
+			Start(func(_ Application) error {
+				events = append(events, "start "+name)
+				if failStart {
+					return errors.New("start failed")
+				}
+				return nil
+			}),
+			Stop(func(_ Application) error {
+				events = append(events, "stop "+name)
+				if failStop {
+					return errors.New("stop failed")
+				}
+				return nil
+			}),
+		)
+	}
+
+	app, err := New("")
+	if assert.NoError(t, err) {
+		app.Add(record("first", false, true), record("second", false, false), record("third", true, false), record("fourth", false, false))
+		assert.NoError(t, app.Init())
+
+		err := app.Start()
+		if assert.Error(t, err) {
+			assert.IsType(t, MultiError{}, err)
+			assert.EqualError(t, err, "start third: start failed; stop first: stop failed")
 		}
+		assert.Equal(t, []string{
+			"start first", "start second", "start third",
+			"stop second", "stop first",
+		}, events)
 	}
 }
 
@@ -366,3 +449,72 @@ func TestApplication_MakeModuleError(t *testing.T) {
 	assert.Equal(t, 1, reloadCount)
 	assert.Equal(t, 2, stopCount)
 }
+
+func TestApplication_MakeModuleDeclarations(t *testing.T) {
+	var mod = MakeModule(
+		Name("orders"),
+		Provides("ordersService"),
+		Requires("ordersDb"),
+		Requires("payments"),
+		Init(func(_ Application) error { return nil }),
+	).(*dynamicModule)
+
+	assert.Len(t, mod.init, 1)
+	assert.Equal(t, "orders", mod.Name())
+	assert.Equal(t, []Key{"ordersService"}, mod.Provides())
+	assert.Equal(t, []Key{"ordersDb", "payments"}, mod.Requires())
+
+	assert.Empty(t, MakeModule().(Named).Name())
+	assert.Equal(t, "go-app", MakeModule().(*dynamicModule).defaultName())
+	assert.Equal(t, "orders", packageName("github.com/some/orders.init.func1"))
+	assert.Equal(t, "main", packageName("main.main"))
+}
+
+func TestApplication_MakeModuleContext(t *testing.T) {
+	var calls []string
+	type ctxKey struct{}
+	record := func(name string) func(context.Context, Application) error {
+		return func(ctx context.Context, _ Application) error {
+			calls = append(calls, name+" "+ctx.Value(ctxKey{}).(string))
+			return nil
+		}
+	}
+
+	var mod = MakeModule(
+		Init(func(_ Application) error {
+			calls = append(calls, "init")
+			return nil
+		}),
+		InitContext(record("initContext")),
+		StartContext(record("startContext")),
+		StopContext(record("stopContext")),
+		ReloadContext(record("reloadContext")),
+	)
+
+	cm, ok := mod.(ContextModule)
+	if assert.True(t, ok) {
+		ctx := context.WithValue(context.Background(), ctxKey{}, "value")
+		assert.NoError(t, cm.InitContext(ctx, nil))
+		assert.NoError(t, cm.StartContext(ctx, nil))
+		assert.NoError(t, cm.StopContext(ctx, nil))
+		assert.NoError(t, cm.ReloadContext(ctx, nil))
+		assert.Equal(t, []string{"init", "initContext value", "startContext value", "stopContext value", "reloadContext value"}, calls)
+
+		cancelled, cancel := context.WithCancel(ctx)
+		cancel()
+		assert.Equal(t, context.Canceled, cm.InitContext(cancelled, nil))
+	}
+
+	adapted := asContextModule(new(plainModule))
+	if assert.IsType(t, moduleAdapter{}, adapted) {
+		assert.EqualError(t, adapted.StartContext(context.Background(), nil), "plain start")
+	}
+	assert.Equal(t, mod, asContextModule(mod))
+}
+
+type plainModule struct{}
+
+func (p *plainModule) Init(_ Application) error   { return nil }
+func (p *plainModule) Start(_ Application) error  { return errors.New("plain start") }
+func (p *plainModule) Stop(_ Application) error   { return nil }
+func (p *plainModule) Reload(_ Application) error { return nil }
@@ -0,0 +1,285 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// ModuleState is the lifecycle state of a module
+type ModuleState int
+
+// The lifecycle states of a module
+const (
+	// StateRegistered modules were added, but not initialized yet
+	StateRegistered ModuleState = iota
+	// StateInitialized modules completed their init phase
+	StateInitialized
+	// StateStarted modules completed their start phase
+	StateStarted
+	// StateStopped modules completed their stop phase
+	StateStopped
+	// StateFailed modules returned an error from their last init, start or stop phase
+	StateFailed
+	// StateDisabled modules are disabled in the config
+	StateDisabled
+)
+
+var stateNames = map[ModuleState]string{
+	StateRegistered:  "registered",
+	StateInitialized: "initialized",
+	StateStarted:     "started",
+	StateStopped:     "stopped",
+	StateFailed:      "failed",
+	StateDisabled:    "disabled",
+}
+
+func (m ModuleState) String() string {
+	if nm, ok := stateNames[m]; ok {
+		return nm
+	}
+	return fmt.Sprintf("state(%d)", int(m))
+}
+
+// MarshalText writes the state as its name
+func (m ModuleState) MarshalText() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+// ModuleInfo describes a module of the application
+type ModuleInfo struct {
+	Name  string
+	State ModuleState
+	// Err is the error of the last phase that failed, reloads included
+	Err error
+	// Timings has the duration of the last run of every phase
+	Timings  map[Phase]time.Duration
+	Provides []Key
+	Requires []Key
+}
+
+// MarshalJSON writes the error as a string and the timings as durations
+func (m ModuleInfo) MarshalJSON() ([]byte, error) {
+	var errMsg string
+	if m.Err != nil {
+		errMsg = m.Err.Error()
+	}
+	timings := make(map[Phase]string, len(m.Timings))
+	for k, v := range m.Timings {
+		timings[k] = v.String()
+	}
+	return json.Marshal(struct {
+		Name     string           `json:"name"`
+		State    ModuleState      `json:"state"`
+		Error    string           `json:"error,omitempty"`
+		Timings  map[Phase]string `json:"timings,omitempty"`
+		Provides []Key            `json:"provides,omitempty"`
+		Requires []Key            `json:"requires,omitempty"`
+	}{m.Name, m.State, errMsg, timings, m.Provides, m.Requires})
+}
+
+func (d *defaultApplication) Modules() []ModuleInfo {
+	// sort without caching the result, this can be called while a lifecycle phase is running
+	mods, err := sortModules(d.modules, d.hasKey)
+	if err != nil {
+		mods = d.modules
+	}
+	res := make([]ModuleInfo, 0, len(mods))
+	for _, mod := range mods {
+		res = append(res, mod.info())
+	}
+	return res
+}
+
+// registration tracks a module that was added to the application
+type registration struct {
+	name      string
+	module    Module
+	lifecycle ContextModule
+
+	// enabled is the last known value of modules.<name>.enabled
+	enabled bool
+
+	// lock guards the state, which is read while phases run
+	lock    sync.Mutex
+	state   ModuleState
+	err     error
+	timings map[Phase]time.Duration
+	checks  map[CheckKind]CheckResult
+}
+
+func newRegistration(name string, mod Module) *registration {
+	return &registration{
+		name:      name,
+		module:    mod,
+		lifecycle: asContextModule(mod),
+		enabled:   true,
+		state:     StateRegistered,
+		timings:   make(map[Phase]time.Duration, 4),
+		checks:    make(map[CheckKind]CheckResult, 2),
+	}
+}
+
+// record the outcome of a phase, the state only changes when the phase fails or when it moves the module forward
+func (r *registration) record(phase Phase, took time.Duration, err error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.timings[phase] = took
+	if err != nil {
+		r.err = err
+		if phase != PhaseReload {
+			r.state = StateFailed
+		}
+		return
+	}
+
+	switch phase {
+	case PhaseInit:
+		r.state = StateInitialized
+	case PhaseStart:
+		r.state = StateStarted
+	case PhaseStop:
+		r.state = StateStopped
+	}
+}
+
+// watches returns the config subtrees the module reloads for, nil when it reloads for every change
+func (r *registration) watches() []string {
+	if cw, ok := r.module.(ConfigWatcher); ok {
+		return cw.Watches()
+	}
+	return nil
+}
+
+// interested is true when the module needs to reload for the changed keys
+func (r *registration) interested(changed []string) bool {
+	subtrees := r.watches()
+	if len(subtrees) == 0 || changed == nil {
+		return true
+	}
+	for _, key := range changed {
+		for _, root := range subtrees {
+			if inSubtree(key, root) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (r *registration) setState(state ModuleState) {
+	r.lock.Lock()
+	r.state = state
+	r.lock.Unlock()
+}
+
+func (r *registration) took(phase Phase) time.Duration {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.timings[phase]
+}
+
+// cachedCheck returns the last result of a check when it is younger than the ttl
+func (r *registration) cachedCheck(kind CheckKind, ttl time.Duration) (CheckResult, bool) {
+	if ttl <= 0 {
+		return CheckResult{}, false
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	res, ok := r.checks[kind]
+	if !ok || time.Since(res.CheckedAt) >= ttl {
+		return CheckResult{}, false
+	}
+	return res, true
+}
+
+func (r *registration) cacheCheck(res CheckResult) {
+	r.lock.Lock()
+	r.checks[res.Kind] = res
+	r.lock.Unlock()
+}
+
+func (r *registration) currentState() ModuleState {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.state
+}
+
+// isInitialized is true when the module was initialized, and has not been stopped since
+func (r *registration) isInitialized() bool {
+	switch r.currentState() {
+	case StateInitialized, StateStarted:
+		return true
+	}
+	return false
+}
+
+func (r *registration) info() ModuleInfo {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	timings := make(map[Phase]time.Duration, len(r.timings))
+	for k, v := range r.timings {
+		timings[k] = v
+	}
+	return ModuleInfo{
+		Name:     r.name,
+		State:    r.state,
+		Err:      r.err,
+		Timings:  timings,
+		Provides: r.provides(),
+		Requires: r.requires(),
+	}
+}
+
+// call the lifecycle method of the module for the phase
+func (r *registration) call(ctx context.Context, phase Phase, app Application) error {
+	switch phase {
+	case PhaseInit:
+		return r.lifecycle.InitContext(ctx, app)
+	case PhaseStart:
+		return r.lifecycle.StartContext(ctx, app)
+	case PhaseStop:
+		return r.lifecycle.StopContext(ctx, app)
+	case PhaseReload:
+		return r.lifecycle.ReloadContext(ctx, app)
+	}
+	return fmt.Errorf("unknown lifecycle phase %q", phase)
+}
+
+func (r *registration) provides() []Key {
+	if dep, ok := r.module.(Dependent); ok {
+		return dep.Provides()
+	}
+	return nil
+}
+
+func (r *registration) requires() []Key {
+	if dep, ok := r.module.(Dependent); ok {
+		return dep.Requires()
+	}
+	return nil
+}
+
+// nameOf a module, modules without a name are named after the last element of their package path.
+// The boolean is true when the module named itself.
+func nameOf(mod Module) (string, bool) {
+	if nm, ok := mod.(Named); ok && nm.Name() != "" {
+		return nm.Name(), true
+	}
+	if dn, ok := mod.(interface{ defaultName() string }); ok && dn.defaultName() != "" {
+		return dn.defaultName(), false
+	}
+	tpe := reflect.TypeOf(mod)
+	for tpe.Kind() == reflect.Ptr {
+		tpe = tpe.Elem()
+	}
+	if tpe.PkgPath() != "" {
+		return packageName(tpe.PkgPath()), false
+	}
+	return tpe.String(), false
+}
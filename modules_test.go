@@ -0,0 +1,98 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func moduleStates(app Application) map[string]ModuleState {
+	res := make(map[string]ModuleState)
+	for _, mod := range app.Modules() {
+		res[mod.Name] = mod.State
+	}
+	return res
+}
+
+func TestModules_States(t *testing.T) {
+	app, err := New("")
+	if assert.NoError(t, err) {
+		app.Add(
+			MakeModule(Name("orders"), Requires("ordersDb")),
+			MakeModule(Name("db"), Provides("ordersDb"), Init(func(app Application) error {
+				app.Set("ordersDb", "db")
+				return nil
+			})),
+		)
+
+		mods := app.Modules()
+		if assert.Len(t, mods, 2) {
+			assert.Equal(t, "db", mods[0].Name)
+			assert.Equal(t, []Key{"ordersDb"}, mods[0].Provides)
+			assert.Equal(t, "orders", mods[1].Name)
+			assert.Equal(t, []Key{"ordersDb"}, mods[1].Requires)
+		}
+		assert.Equal(t, map[string]ModuleState{"db": StateRegistered, "orders": StateRegistered}, moduleStates(app))
+
+		assert.NoError(t, app.Init())
+		assert.Equal(t, map[string]ModuleState{"db": StateInitialized, "orders": StateInitialized}, moduleStates(app))
+
+		assert.NoError(t, app.Start())
+		assert.Equal(t, map[string]ModuleState{"db": StateStarted, "orders": StateStarted}, moduleStates(app))
+		for _, mod := range app.Modules() {
+			assert.Contains(t, mod.Timings, PhaseInit)
+			assert.Contains(t, mod.Timings, PhaseStart)
+			assert.NoError(t, mod.Err)
+		}
+
+		assert.NoError(t, app.Stop())
+		assert.Equal(t, map[string]ModuleState{"db": StateStopped, "orders": StateStopped}, moduleStates(app))
+	}
+}
+
+func TestModules_Failed(t *testing.T) {
+	app, err := New("")
+	if assert.NoError(t, err) {
+		app.Add(
+			MakeModule(Name("first")),
+			MakeModule(Name("broken"), Start(func(_ Application) error { return errors.New("no port") })),
+		)
+
+		assert.NoError(t, app.Init())
+		assert.Error(t, app.Start())
+
+		mods := app.Modules()
+		if assert.Len(t, mods, 2) {
+			assert.Equal(t, StateStopped, mods[0].State)
+			assert.Equal(t, StateFailed, mods[1].State)
+			assert.EqualError(t, mods[1].Err, "start broken: no port")
+		}
+	}
+}
+
+func TestModules_Disabled(t *testing.T) {
+	app, err := New("")
+	if assert.NoError(t, err) {
+		app.Config().Set("modules.orders.enabled", false)
+		app.Add(MakeModule(Name("orders")))
+
+		assert.NoError(t, app.Init())
+		assert.Equal(t, StateDisabled, moduleStates(app)["orders"])
+	}
+}
+
+func TestModules_JSON(t *testing.T) {
+	info := ModuleInfo{
+		Name:     "orders",
+		State:    StateFailed,
+		Err:      errors.New("boom"),
+		Requires: []Key{"ordersDb"},
+	}
+	b, err := json.Marshal(info)
+	if assert.NoError(t, err) {
+		assert.JSONEq(t, `{"name":"orders","state":"failed","error":"boom","requires":["ordersDb"]}`, string(b))
+	}
+	assert.Equal(t, "state(42)", ModuleState(42).String())
+}
@@ -0,0 +1,122 @@
+package app
+
+import (
+	"os"
+
+	"github.com/casualjim/go-app/logging"
+	"github.com/fsnotify/fsnotify"
+)
+
+// Option configures an application made with NewWithOptions
+type Option func(*options)
+
+// options default to the environment variables and package variables New uses
+type options struct {
+	configFile   string
+	configPaths  []string
+	remoteURL    string
+	keyring      string
+	version      string
+	profile      string
+	loggers      *logging.Registry
+	signals      bool
+	watchSources bool
+	sources      []configLayer
+	reloadHook   func(fsnotify.Event)
+}
+
+func newOptions() *options {
+	version := "dev"
+	if Version != "" {
+		version = Version
+	}
+	return &options{
+		remoteURL:    os.Getenv("CONFIG_REMOTE_URL"),
+		keyring:      os.Getenv("CONFIG_KEYRING"),
+		version:      version,
+		signals:      true,
+		watchSources: true,
+	}
+}
+
+// WithConfigFile reads the config from the file instead of searching the config paths for a config file
+func WithConfigFile(path string) Option {
+	return func(o *options) {
+		o.configFile = path
+	}
+}
+
+// WithConfigPaths are the directories that are searched for a config file, instead of CONFIG_PATH or the default paths
+func WithConfigPaths(paths ...string) Option {
+	return func(o *options) {
+		o.configPaths = paths
+	}
+}
+
+// WithRemoteURL reads the config from etcd or consul, instead of from CONFIG_REMOTE_URL.
+// An empty url disables the remote config.
+func WithRemoteURL(url string) Option {
+	return func(o *options) {
+		o.remoteURL = url
+	}
+}
+
+// WithKeyring decrypts the remote config with the gpg keyring, instead of with CONFIG_KEYRING.
+// An empty keyring means the remote config isn't encrypted.
+func WithKeyring(path string) Option {
+	return func(o *options) {
+		o.keyring = path
+	}
+}
+
+// WithVersion of the application, instead of the Version variable
+func WithVersion(version string) Option {
+	return func(o *options) {
+		o.version = version
+	}
+}
+
+// WithProfile loads config.<profile>.* from the config paths over the config, instead of the profile
+// of a --profile flag or APP_ENV
+func WithProfile(profile string) Option {
+	return func(o *options) {
+		o.profile = profile
+	}
+}
+
+// WithLoggers uses the registry for the loggers of the application, it is configured with the config of the application
+func WithLoggers(registry *logging.Registry) Option {
+	return func(o *options) {
+		o.loggers = registry
+	}
+}
+
+// WithSignalHandling enables or disables the signal handlers, this is enabled by default.
+// Without it the diagnostic signals aren't handled and Run only shuts down when its context is done
+// or a component fails.
+func WithSignalHandling(enabled bool) Option {
+	return func(o *options) {
+		o.signals = enabled
+	}
+}
+
+// WithWatchers enables or disables watching the sources of the config for changes, this is enabled by default
+func WithWatchers(enabled bool) Option {
+	return func(o *options) {
+		o.watchSources = enabled
+	}
+}
+
+// WithConfigSource adds a source to the config, it is loaded before the loggers are configured
+func WithConfigSource(source ConfigSource, priority Priority) Option {
+	return func(o *options) {
+		o.sources = append(o.sources, configLayer{name: source.Name(), priority: priority, source: source})
+	}
+}
+
+// withReloadHook is called after every config change, tests use it to wait for changes
+func withReloadHook(hook func(fsnotify.Event)) Option {
+	return func(o *options) {
+		o.reloadHook = hook
+	}
+}
@@ -0,0 +1,89 @@
+package app
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/casualjim/go-app/logging"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOptions_ConfigPaths(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-app")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	first, second := filepath.Join(dir, "first"), filepath.Join(dir, "second")
+	for _, d := range []string{first, second} {
+		if !assert.NoError(t, os.MkdirAll(d, 0755)) {
+			return
+		}
+		if !assert.NoError(t, ioutil.WriteFile(filepath.Join(d, "config.json"), []byte(`{"name": "`+filepath.Base(d)+`"}`), 0644)) {
+			return
+		}
+	}
+
+	// differently configured applications in the same process
+	app1, err := NewWithOptions("app1", WithConfigPaths(first), WithVersion("1.0.0"), WithWatchers(false))
+	if assert.NoError(t, err) {
+		assert.Equal(t, "first", app1.Config().GetString("name"))
+		assert.Equal(t, "1.0.0", app1.Info().Version)
+	}
+	app2, err := NewWithOptions("app2", WithConfigPaths(second), WithWatchers(false))
+	if assert.NoError(t, err) {
+		assert.Equal(t, "second", app2.Config().GetString("name"))
+		assert.Equal(t, "dev", app2.Info().Version)
+	}
+
+	app3, err := NewWithOptions("app3", WithConfigFile(filepath.Join(first, "config.json")), WithWatchers(false))
+	if assert.NoError(t, err) {
+		assert.Equal(t, "first", app3.Config().GetString("name"))
+	}
+}
+
+func TestOptions_RemoteURL(t *testing.T) {
+	os.Setenv("CONFIG_REMOTE_URL", "etcd://[/")
+	defer os.Unsetenv("CONFIG_REMOTE_URL")
+
+	_, err := New("")
+	assert.Error(t, err)
+
+	// the option takes precedence over the environment variable
+	_, err = NewWithOptions("", WithRemoteURL(""), WithWatchers(false))
+	assert.NoError(t, err)
+
+	_, err = NewWithOptions("", WithRemoteURL("zookeeper://127.0.0.1:2379/app/config.json"))
+	assert.EqualError(t, err, "Unsupported Remote Provider Type \"zookeeper\"")
+}
+
+func TestOptions_ConfigSourceAndLoggers(t *testing.T) {
+	registry := logging.NewRegistry(nil, logrus.Fields{"app": "options"})
+	defaults := EmbeddedSource("defaults.yaml", []byte("logging:\n  root:\n    level: debug\n"))
+
+	appi, err := NewWithOptions("options", WithLoggers(registry), WithConfigSource(defaults, PriorityDefaults), WithWatchers(false))
+	if assert.NoError(t, err) {
+		assert.Same(t, registry, appi.Loggers())
+		// the defaults are loaded before the loggers are configured
+		assert.Equal(t, logrus.DebugLevel, registry.Levels()["root"])
+		assert.Equal(t, "defaults.yaml", appi.ConfigOrigin("logging.root.level"))
+
+		app := appi.(*defaultApplication)
+		assert.False(t, app.watchSources)
+	}
+}
+
+func TestOptions_WithoutSignalHandling(t *testing.T) {
+	app, err := NewWithOptions("nosignals", WithSignalHandling(false), WithWatchers(false))
+	if assert.NoError(t, err) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+		assert.Equal(t, ExitOK, app.RunContext(ctx))
+	}
+}
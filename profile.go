@@ -0,0 +1,56 @@
+package app
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// activeProfile is the profile of WithProfile, or of a --profile flag of a flag source, or of APP_ENV
+func activeProfile(o *options) (string, error) {
+	profile := o.profile
+	if profile == "" {
+		for _, layer := range o.sources {
+			if src, ok := layer.source.(*flagSource); ok {
+				if flag := src.flags.Lookup("profile"); flag != nil && flag.Changed {
+					profile = flag.Value.String()
+				}
+			}
+		}
+	}
+	if profile == "" {
+		profile = os.Getenv("APP_ENV")
+	}
+	if strings.ContainsAny(profile, `/\`) || profile == "." || profile == ".." {
+		return "", fmt.Errorf("profile %q can't be used in the name of a config file", profile)
+	}
+	return profile, nil
+}
+
+// profileConfigFile finds config.<profile>.* in the config paths, or <name>.<profile>.* next to the config file.
+// It is empty when there is no config file for the profile.
+func profileConfigFile(name, profile string, o *options) string {
+	if profile == "" {
+		return ""
+	}
+	base, paths := "config", o.configPaths
+	if o.configFile != "" {
+		dir, fname := filepath.Split(o.configFile)
+		base, paths = strings.TrimSuffix(fname, filepath.Ext(fname)), []string{dir}
+	} else if paths == nil {
+		paths = defaultConfigPaths(name)
+	}
+
+	for _, path := range paths {
+		for _, ext := range viper.SupportedExts {
+			file := filepath.Join(path, base+"."+profile+"."+ext)
+			if fi, err := os.Stat(file); err == nil && !fi.IsDir() {
+				return file
+			}
+		}
+	}
+	return ""
+}
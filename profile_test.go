@@ -0,0 +1,121 @@
+package app
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProfile_Active(t *testing.T) {
+	os.Setenv("APP_ENV", "staging")
+	defer os.Unsetenv("APP_ENV")
+
+	profile, err := activeProfile(newOptions())
+	if assert.NoError(t, err) {
+		assert.Equal(t, "staging", profile)
+	}
+
+	flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	flags.String("profile", "", "the config profile")
+	o := newOptions()
+	WithConfigSource(FlagSource(flags), PriorityFlags)(o)
+	profile, _ = activeProfile(o)
+	assert.Equal(t, "staging", profile, "the flag isn't set")
+
+	if assert.NoError(t, flags.Parse([]string{"--profile", "production"})) {
+		profile, _ = activeProfile(o)
+		assert.Equal(t, "production", profile)
+	}
+
+	WithProfile("test")(o)
+	profile, _ = activeProfile(o)
+	assert.Equal(t, "test", profile)
+
+	WithProfile("../secrets")(o)
+	_, err = activeProfile(o)
+	assert.Error(t, err)
+}
+
+func TestProfile_Overlay(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-app")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	base, overlay := filepath.Join(dir, "config.yaml"), filepath.Join(dir, "config.production.yaml")
+	write := func(file, content string) {
+		if !assert.NoError(t, ioutil.WriteFile(file, []byte(content), 0644)) {
+			t.FailNow()
+		}
+	}
+	write(base, "config:\n  debounce: 10ms\nname: orders\ndb:\n  host: localhost\n  port: 5432\n")
+	write(overlay, "db:\n  host: db.production\n")
+
+	app, err := NewWithOptions("profile",
+		WithConfigPaths(dir),
+		WithProfile("production"),
+		WithSignalHandling(false),
+	)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer app.Close()
+
+	assert.Equal(t, "production", app.Profile())
+
+	// the profile is deep merged over the config
+	assert.Equal(t, "db.production", app.Config().GetString("db.host"))
+	assert.Equal(t, 5432, app.Config().GetInt("db.port"))
+	assert.Equal(t, "orders", app.Config().GetString("name"))
+	assert.Equal(t, overlay, app.ConfigOrigin("db.host"))
+	assert.Equal(t, base, app.ConfigOrigin("db.port"))
+
+	// both files are watched, once the watchers started
+	time.Sleep(100 * time.Millisecond)
+	write(overlay, "db:\n  host: db2.production\n")
+	write(base, "config:\n  debounce: 10ms\nname: payments\ndb:\n  host: localhost\n  port: 5432\n")
+
+	d := app.(*defaultApplication)
+	assert.Eventually(t, func() bool {
+		d.reloadLock.Lock()
+		defer d.reloadLock.Unlock()
+		return d.config.GetString("db.host") == "db2.production" && d.config.GetString("name") == "payments"
+	}, 2*time.Second, 10*time.Millisecond)
+}
+
+func TestProfile_ConfigFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-app")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "orders.json")
+	if !assert.NoError(t, ioutil.WriteFile(file, []byte(`{"name": "orders", "port": 8080}`), 0644)) {
+		return
+	}
+	if !assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "orders.staging.toml"), []byte(`port = 9090`), 0644)) {
+		return
+	}
+
+	app, err := NewWithOptions("profile", WithConfigFile(file), WithProfile("staging"), WithSignalHandling(false), WithWatchers(false))
+	if assert.NoError(t, err) {
+		defer app.Close()
+		assert.Equal(t, "orders", app.Config().GetString("name"))
+		assert.Equal(t, 9090, app.Config().GetInt("port"))
+	}
+
+	// a profile without a config file only sets the profile
+	app, err = NewWithOptions("profile", WithConfigFile(file), WithProfile("qa"), WithSignalHandling(false), WithWatchers(false))
+	if assert.NoError(t, err) {
+		defer app.Close()
+		assert.Equal(t, "qa", app.Profile())
+		assert.Equal(t, 8080, app.Config().GetInt("port"))
+	}
+}
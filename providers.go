@@ -0,0 +1,172 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Scope determines how often a provider builds its value
+type Scope int
+
+const (
+	// Singleton values are built once, when they are first requested
+	Singleton Scope = iota
+	// Transient values are built every time they are requested
+	Transient
+)
+
+func (s Scope) String() string {
+	switch s {
+	case Singleton:
+		return "singleton"
+	case Transient:
+		return "transient"
+	}
+	return fmt.Sprintf("scope(%d)", int(s))
+}
+
+// Provider builds the value for a key.
+// The application it receives tracks the keys being built, so it should be used to get dependencies.
+type Provider func(Application) (interface{}, error)
+
+// ErrCyclicProvider is wrapped by the error returned when providers depend on each other
+var ErrCyclicProvider = errors.New("cyclic construction")
+
+type providerEntry struct {
+	provider Provider
+	scope    Scope
+	lock     sync.Mutex
+}
+
+// Provide registers a type-safe provider for the key, the value is built when it is first requested.
+// The scope defaults to Singleton.
+func Provide[T any](a Application, key Key, fn func(Application) (T, error), scope ...Scope) error {
+	sc := Singleton
+	if len(scope) > 0 {
+		sc = scope[0]
+	}
+	return a.SetProvider(key, func(app Application) (interface{}, error) {
+		return fn(app)
+	}, sc)
+}
+
+func (d *defaultApplication) SetProvider(key Key, provider Provider, scope Scope) error {
+	if provider == nil {
+		return fmt.Errorf("provider for %q is nil", key)
+	}
+	if scope != Singleton && scope != Transient {
+		return fmt.Errorf("provider for %q has an unknown %v", key, scope)
+	}
+
+	d.regLock.Lock()
+	d.providers[key] = &providerEntry{provider: provider, scope: scope}
+	// a previously built or set value would shadow the new provider
+	delete(d.registry, key)
+	d.regLock.Unlock()
+	return nil
+}
+
+// hasKey is true when a value or a provider is registered at the key, it doesn't build anything
+func (d *defaultApplication) hasKey(key Key) bool {
+	d.regLock.Lock()
+	defer d.regLock.Unlock()
+	_, hasValue := d.registry[key]
+	_, hasProvider := d.providers[key]
+	return hasValue || hasProvider
+}
+
+// lookup the value at the key, errors from providers are returned instead of logged
+func (d *defaultApplication) lookup(key Key) (interface{}, bool, error) {
+	return d.resolve(key, nil)
+}
+
+// resolve the value at the key, building it when a provider is registered.
+// The chain contains the keys that are being built and is used to detect cycles.
+func (d *defaultApplication) resolve(key Key, chain []Key) (interface{}, bool, error) {
+	d.regLock.Lock()
+	value, ok := d.registry[key]
+	entry, hasProvider := d.providers[key]
+	d.regLock.Unlock()
+
+	if ok {
+		return value, true, nil
+	}
+	if !hasProvider {
+		return nil, false, nil
+	}
+
+	for i, k := range chain {
+		if k == key {
+			var names []string
+			for _, c := range append(chain[i:], key) {
+				names = append(names, string(c))
+			}
+			return nil, false, fmt.Errorf("%w: %s", ErrCyclicProvider, strings.Join(names, " -> "))
+		}
+	}
+
+	build := func() (interface{}, error) {
+		next := make([]Key, len(chain), len(chain)+1)
+		copy(next, chain)
+		return entry.provider(&resolvingApplication{defaultApplication: d, chain: append(next, key)})
+	}
+
+	if entry.scope == Transient {
+		value, err := build()
+		if err != nil {
+			return nil, false, err
+		}
+		return value, true, nil
+	}
+
+	entry.lock.Lock()
+	defer entry.lock.Unlock()
+
+	// another caller might have built the value while we waited
+	d.regLock.Lock()
+	value, ok = d.registry[key]
+	d.regLock.Unlock()
+	if ok {
+		return value, true, nil
+	}
+
+	value, err := build()
+	if err != nil {
+		return nil, false, err
+	}
+
+	d.regLock.Lock()
+	// only cache when the provider wasn't replaced in the meantime
+	if d.providers[key] == entry {
+		d.registry[key] = value
+	}
+	d.regLock.Unlock()
+	return value, true, nil
+}
+
+// resolvingApplication is the application that is passed to providers,
+// it remembers which keys are being built so cycles result in an error instead of a deadlock.
+type resolvingApplication struct {
+	*defaultApplication
+	chain []Key
+}
+
+func (r *resolvingApplication) Get(key Key) interface{} {
+	value, _ := r.GetOK(key)
+	return value
+}
+
+func (r *resolvingApplication) GetOK(key Key) (interface{}, bool) {
+	value, ok, err := r.lookup(key)
+	if err != nil {
+		r.Logger().Errorf("resolving %q: %v", key, err)
+		return nil, false
+	}
+	return value, ok
+}
+
+func (r *resolvingApplication) lookup(key Key) (interface{}, bool, error) {
+	return r.defaultApplication.resolve(key, r.chain)
+}
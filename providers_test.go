@@ -0,0 +1,133 @@
+package app
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type ordersClient struct {
+	id int32
+}
+
+func TestProviders_Singleton(t *testing.T) {
+	app, err := New("")
+	if assert.NoError(t, err) {
+		var built int32
+		assert.NoError(t, Provide(app, "orders", func(_ Application) (*ordersClient, error) {
+			return &ordersClient{id: atomic.AddInt32(&built, 1)}, nil
+		}))
+		assert.Equal(t, int32(0), atomic.LoadInt32(&built))
+
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				client := app.Get("orders").(*ordersClient)
+				assert.Equal(t, int32(1), client.id)
+			}()
+		}
+		wg.Wait()
+		assert.Equal(t, int32(1), atomic.LoadInt32(&built))
+
+		client, err := Resolve[*ordersClient](app, "orders")
+		if assert.NoError(t, err) {
+			assert.Equal(t, int32(1), client.id)
+		}
+	}
+}
+
+func TestProviders_Transient(t *testing.T) {
+	app, err := New("")
+	if assert.NoError(t, err) {
+		var built int32
+		assert.NoError(t, Provide(app, "orders", func(_ Application) (*ordersClient, error) {
+			return &ordersClient{id: atomic.AddInt32(&built, 1)}, nil
+		}, Transient))
+
+		assert.Equal(t, int32(1), MustResolve[*ordersClient](app, "orders").id)
+		assert.Equal(t, int32(2), MustResolve[*ordersClient](app, "orders").id)
+	}
+}
+
+func TestProviders_Dependencies(t *testing.T) {
+	app, err := New("")
+	if assert.NoError(t, err) {
+		assert.NoError(t, Provide(app, "orders", func(a Application) (*ordersClient, error) {
+			id, err := Resolve[int32](a, "ordersId")
+			if err != nil {
+				return nil, err
+			}
+			return &ordersClient{id: id}, nil
+		}))
+		assert.NoError(t, Provide(app, "ordersId", func(_ Application) (int32, error) {
+			return 7, nil
+		}))
+
+		assert.Equal(t, int32(7), MustResolve[*ordersClient](app, "orders").id)
+	}
+}
+
+func TestProviders_Cycle(t *testing.T) {
+	app, err := New("")
+	if assert.NoError(t, err) {
+		for _, pair := range [][2]Key{{"a", "b"}, {"b", "c"}, {"c", "a"}} {
+			dep := pair[1]
+			assert.NoError(t, app.SetProvider(pair[0], func(a Application) (interface{}, error) {
+				return Resolve[string](a, dep)
+			}, Singleton))
+		}
+
+		_, err := Resolve[string](app, "a")
+		if assert.Error(t, err) {
+			assert.True(t, errors.Is(err, ErrCyclicProvider))
+			assert.Contains(t, err.Error(), "cyclic construction: a -> b -> c -> a")
+		}
+		_, ok := app.GetOK("b")
+		assert.False(t, ok)
+	}
+}
+
+func TestProviders_Errors(t *testing.T) {
+	app, err := New("")
+	if assert.NoError(t, err) {
+		fail := true
+		assert.NoError(t, Provide(app, "orders", func(_ Application) (*ordersClient, error) {
+			if fail {
+				return nil, errors.New("connection refused")
+			}
+			return &ordersClient{id: 1}, nil
+		}))
+
+		_, err := Resolve[*ordersClient](app, "orders")
+		assert.EqualError(t, err, `building "orders": connection refused`)
+		assert.Nil(t, app.Get("orders"))
+
+		// a failed singleton is retried on the next request
+		fail = false
+		assert.Equal(t, int32(1), MustResolve[*ordersClient](app, "orders").id)
+
+		assert.Error(t, app.SetProvider("nil", nil, Singleton))
+		assert.Error(t, app.SetProvider("unknown", func(_ Application) (interface{}, error) { return nil, nil }, Scope(5)))
+		assert.Equal(t, "scope(5)", Scope(5).String())
+	}
+}
+
+func TestProviders_SatisfyRequirements(t *testing.T) {
+	var built bool
+	app, err := New("")
+	if assert.NoError(t, err) {
+		assert.NoError(t, Provide(app, "ordersDb", func(_ Application) (string, error) {
+			built = true
+			return "db", nil
+		}))
+		app.Add(MakeModule(Requires("ordersDb")))
+		assert.NoError(t, app.Init())
+		// sorting the modules doesn't build the value
+		assert.False(t, built)
+	}
+}
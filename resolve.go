@@ -0,0 +1,101 @@
+package app
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// TypeMismatchError is returned when the value registered at a key doesn't have the requested type
+type TypeMismatchError struct {
+	Key      Key
+	Expected reflect.Type
+	Actual   reflect.Type
+}
+
+func (e *TypeMismatchError) Error() string {
+	actual := "<nil>"
+	if e.Actual != nil {
+		actual = e.Actual.String()
+	}
+	return fmt.Sprintf("key %q: expected a value of type %s, but found %s", e.Key, e.Expected, actual)
+}
+
+// lookuper is implemented by the applications of this package,
+// unlike GetOK it reports why a provider failed to build a value
+type lookuper interface {
+	lookup(Key) (interface{}, bool, error)
+}
+
+// lookupKey gets the value at the key, with the error of its provider when the application supports that
+func lookupKey(a Application, key Key) (interface{}, bool, error) {
+	l, ok := a.(lookuper)
+	if !ok {
+		value, ok := a.GetOK(key)
+		return value, ok, nil
+	}
+	value, ok, err := l.lookup(key)
+	if err != nil {
+		return nil, false, fmt.Errorf("building %q: %w", key, err)
+	}
+	return value, ok, nil
+}
+
+// Resolve the value registered at the key as a T.
+// It returns an error wrapping ErrModuleUnknown when nothing is registered at the key,
+// and a TypeMismatchError when the value isn't a T.
+func Resolve[T any](a Application, key Key) (T, error) {
+	var zero T
+	value, ok, err := lookupKey(a, key)
+	if err != nil {
+		return zero, err
+	}
+	if !ok {
+		return zero, fmt.Errorf("%w: %q", ErrModuleUnknown, key)
+	}
+
+	res, ok := value.(T)
+	if !ok {
+		return zero, &TypeMismatchError{
+			Key:      key,
+			Expected: reflect.TypeOf((*T)(nil)).Elem(),
+			Actual:   reflect.TypeOf(value),
+		}
+	}
+	return res, nil
+}
+
+// MustResolve the value registered at the key as a T, panics when that is not possible
+func MustResolve[T any](a Application, key Key) T {
+	res, err := Resolve[T](a, key)
+	if err != nil {
+		panic(err)
+	}
+	return res
+}
+
+// TypedKey is a key that knows the type of the value registered at it
+//
+//	const OrdersDB app.TypedKey[*sql.DB] = "ordersDb"
+//
+//	db, err := OrdersDB.Get(application)
+type TypedKey[T any] Key
+
+// Key returns the untyped key
+func (k TypedKey[T]) Key() Key {
+	return Key(k)
+}
+
+// Get the value registered at this key from the application
+func (k TypedKey[T]) Get(a Application) (T, error) {
+	return Resolve[T](a, Key(k))
+}
+
+// MustGet the value registered at this key from the application, panics when that is not possible
+func (k TypedKey[T]) MustGet(a Application) T {
+	return MustResolve[T](a, Key(k))
+}
+
+// Set the value for this key in the application
+func (k TypedKey[T]) Set(a Application, value T) error {
+	return a.Set(Key(k), value)
+}
@@ -0,0 +1,69 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResolve(t *testing.T) {
+	app, err := New("")
+	if assert.NoError(t, err) {
+		fm := &firstModule{arb: "value"}
+		app.Set(firstModuleKey, fm)
+
+		res, err := Resolve[*firstModule](app, firstModuleKey)
+		if assert.NoError(t, err) {
+			assert.Equal(t, fm, res)
+		}
+
+		// interfaces resolve too
+		str, err := Resolve[fmt.Stringer](app, firstModuleKey)
+		assert.Nil(t, str)
+		assert.EqualError(t, err, `key "firstModule": expected a value of type fmt.Stringer, but found *app.firstModule`)
+
+		_, err = Resolve[string](app, firstModuleKey)
+		var mismatch *TypeMismatchError
+		if assert.True(t, errors.As(err, &mismatch)) {
+			assert.Equal(t, "string", mismatch.Expected.String())
+			assert.Equal(t, "*app.firstModule", mismatch.Actual.String())
+		}
+
+		_, err = Resolve[string](app, "unknown")
+		assert.True(t, errors.Is(err, ErrModuleUnknown))
+		assert.EqualError(t, err, `unknown module: "unknown"`)
+
+		app.Set("nothing", nil)
+		_, err = Resolve[*firstModule](app, "nothing")
+		assert.EqualError(t, err, `key "nothing": expected a value of type *app.firstModule, but found <nil>`)
+
+		assert.Equal(t, fm, MustResolve[*firstModule](app, firstModuleKey))
+		assert.Panics(t, func() { MustResolve[int](app, firstModuleKey) })
+	}
+}
+
+func TestTypedKey(t *testing.T) {
+	const count TypedKey[int] = "count"
+
+	app, err := New("")
+	if assert.NoError(t, err) {
+		assert.Equal(t, Key("count"), count.Key())
+
+		_, err := count.Get(app)
+		assert.Error(t, err)
+		assert.Panics(t, func() { count.MustGet(app) })
+
+		assert.NoError(t, count.Set(app, 42))
+		res, err := count.Get(app)
+		if assert.NoError(t, err) {
+			assert.Equal(t, 42, res)
+		}
+		assert.Equal(t, 42, count.MustGet(app))
+
+		app.Set(count.Key(), "42")
+		_, err = count.Get(app)
+		assert.EqualError(t, err, `key "count": expected a value of type int, but found string`)
+	}
+}
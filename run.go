@@ -0,0 +1,168 @@
+package app
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+const (
+	// ExitOK is returned by Run when the application shut down cleanly
+	ExitOK = 0
+	// ExitFailure is returned by Run when a module failed or a component reported a failure
+	ExitFailure = 1
+	// ExitForced is used when a second signal arrives during shutdown
+	ExitForced = 2
+)
+
+// DefaultShutdownTimeout is the deadline for stopping the modules when Run shuts down
+// and no stop timeout is configured at lifecycle.timeouts.stop
+var DefaultShutdownTimeout = 30 * time.Second
+
+// exit is replaced in tests
+var exit = os.Exit
+
+// handleSignals calls the handler for every signal received, until the returned function is called.
+// This is the only place where the application subscribes to signals.
+func (d *defaultApplication) handleSignals(handler func(os.Signal), sigs ...os.Signal) func() {
+	ch := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	signal.Notify(ch, sigs...)
+
+	d.background.Add(1)
+	go func() {
+		defer d.background.Done()
+		for {
+			select {
+			case sig := <-ch:
+				handler(sig)
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		signal.Stop(ch)
+		close(done)
+	}
+}
+
+func (d *defaultApplication) Fail(err error) {
+	if err == nil {
+		return
+	}
+	select {
+	case d.failures <- err:
+	default:
+		// a failure is already pending, the application is shutting down anyway
+		d.Logger().Errorf("component failure: %v", err)
+	}
+}
+
+func (d *defaultApplication) Run() int {
+	return d.RunContext(context.Background())
+}
+
+func (d *defaultApplication) RunContext(ctx context.Context) int {
+	logger := d.Logger()
+
+	// the first signal cancels the startup or shuts the application down, the second one exits right away.
+	// Both are handled from before Init, so a module that hangs while starting can't keep the process alive.
+	startup, cancelStartup := context.WithCancel(ctx)
+	defer cancelStartup()
+	received := make(chan os.Signal, 1)
+	stopped := make(chan struct{})
+	defer close(stopped)
+	if d.signals {
+		signals := make(chan os.Signal, 2)
+		stopSignals := d.handleSignals(func(sig os.Signal) {
+			select {
+			case signals <- sig:
+			default:
+			}
+		}, os.Interrupt, syscall.SIGTERM)
+		defer stopSignals()
+
+		go func() {
+			select {
+			case sig := <-signals:
+				received <- sig
+				cancelStartup()
+			case <-stopped:
+				return
+			}
+			select {
+			case sig := <-signals:
+				logger.Errorf("received %v during shutdown, exiting immediately", sig)
+				exit(ExitForced)
+			case <-stopped:
+			}
+		}()
+	}
+
+	if err := d.InitContext(startup); err != nil {
+		return d.startupFailed("initializing application", err, received)
+	}
+	if err := d.StartContext(startup); err != nil {
+		// modules that were initialized but never started still need to be stopped
+		d.shutdown()
+		return d.startupFailed("starting application", err, received)
+	}
+	for _, mod := range d.Modules() {
+		logger.Debugf("module %s is %s, init took %v, start took %v", mod.Name, mod.State, mod.Timings[PhaseInit], mod.Timings[PhaseStart])
+	}
+	if d.profile != "" {
+		logger.Infof("application started with profile %s", d.profile)
+	} else {
+		logger.Infoln("application started")
+	}
+
+	code := ExitOK
+	select {
+	case sig := <-received:
+		logger.Infof("received %v, shutting down", sig)
+	case <-ctx.Done():
+		logger.Infof("shutting down: %v", ctx.Err())
+	case err := <-d.failures:
+		logger.Errorf("shutting down after component failure: %v", err)
+		code = ExitFailure
+	}
+
+	if err := d.shutdown(); err != nil {
+		code = ExitFailure
+	}
+	return code
+}
+
+// startupFailed returns the exit code for a startup that didn't complete,
+// a startup that was interrupted by a signal is a regular shutdown
+func (d *defaultApplication) startupFailed(what string, err error, received <-chan os.Signal) int {
+	select {
+	case sig := <-received:
+		d.Logger().Infof("received %v during startup, shutting down", sig)
+		return ExitOK
+	default:
+		d.Logger().Errorf("%s: %v", what, err)
+		return ExitFailure
+	}
+}
+
+// shutdown stops the modules with the shutdown deadline
+func (d *defaultApplication) shutdown() error {
+	timeout := d.config.GetDuration("lifecycle.timeouts.stop")
+	if timeout <= 0 {
+		timeout = DefaultShutdownTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	if err := d.StopContext(ctx); err != nil {
+		d.Logger().Errorf("stopping application: %v", err)
+		return err
+	}
+	d.Logger().Infoln("application stopped")
+	return nil
+}
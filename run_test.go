@@ -0,0 +1,230 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type runRecorder struct {
+	lock    sync.Mutex
+	events  []string
+	started chan struct{}
+}
+
+func (r *runRecorder) record(event string) {
+	r.lock.Lock()
+	r.events = append(r.events, event)
+	r.lock.Unlock()
+}
+
+func (r *runRecorder) Events() []string {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return append([]string(nil), r.events...)
+}
+
+func (r *runRecorder) module(stop func() error) Module {
+	return MakeModule(
+		Name("recorder"),
+		Init(func(_ Application) error {
+			r.record("init")
+			return nil
+		}),
+		Start(func(_ Application) error {
+			r.record("start")
+			close(r.started)
+			return nil
+		}),
+		Stop(func(_ Application) error {
+			r.record("stop")
+			if stop != nil {
+				return stop()
+			}
+			return nil
+		}),
+	)
+}
+
+func TestRun_Signal(t *testing.T) {
+	rec := &runRecorder{started: make(chan struct{})}
+	app, err := New("")
+	if assert.NoError(t, err) {
+		app.Add(rec.module(nil))
+
+		go func() {
+			<-rec.started
+			syscall.Kill(syscall.Getpid(), syscall.SIGTERM)
+		}()
+
+		assert.Equal(t, ExitOK, app.Run())
+		assert.Equal(t, []string{"init", "start", "stop"}, rec.Events())
+	}
+}
+
+func TestRun_Context(t *testing.T) {
+	rec := &runRecorder{started: make(chan struct{})}
+	app, err := New("")
+	if assert.NoError(t, err) {
+		app.Add(rec.module(nil))
+
+		ctx, cancel := context.WithCancel(context.Background())
+		go func() {
+			<-rec.started
+			cancel()
+		}()
+
+		assert.Equal(t, ExitOK, app.RunContext(ctx))
+		assert.Equal(t, []string{"init", "start", "stop"}, rec.Events())
+	}
+}
+
+func TestRun_Failure(t *testing.T) {
+	rec := &runRecorder{started: make(chan struct{})}
+	app, err := New("")
+	if assert.NoError(t, err) {
+		app.Add(rec.module(nil))
+
+		go func() {
+			<-rec.started
+			app.Fail(errors.New("listener closed"))
+		}()
+
+		assert.Equal(t, ExitFailure, app.Run())
+		assert.Equal(t, []string{"init", "start", "stop"}, rec.Events())
+	}
+}
+
+func TestRun_StartFailure(t *testing.T) {
+	var stopped bool
+	app, err := New("")
+	if assert.NoError(t, err) {
+		app.Add(
+			MakeModule(Name("first"), Stop(func(_ Application) error {
+				stopped = true
+				return nil
+			})),
+			MakeModule(Name("second"), Start(func(_ Application) error {
+				return errors.New("expected")
+			})),
+		)
+
+		assert.Equal(t, ExitFailure, app.Run())
+		assert.True(t, stopped)
+	}
+}
+
+func TestRun_ForcedExit(t *testing.T) {
+	oldExit := exit
+	defer func() { exit = oldExit }()
+	exited := make(chan int, 1)
+	exit = func(code int) { exited <- code }
+
+	rec := &runRecorder{started: make(chan struct{})}
+	stopping := make(chan struct{})
+	release := make(chan struct{})
+	app, err := New("")
+	if assert.NoError(t, err) {
+		app.Add(rec.module(func() error {
+			close(stopping)
+			<-release
+			return nil
+		}))
+
+		go func() {
+			<-rec.started
+			syscall.Kill(syscall.Getpid(), syscall.SIGINT)
+			<-stopping
+			syscall.Kill(syscall.Getpid(), syscall.SIGINT)
+		}()
+
+		done := make(chan int)
+		go func() { done <- app.Run() }()
+
+		select {
+		case code := <-exited:
+			assert.Equal(t, ExitForced, code)
+		case <-time.After(5 * time.Second):
+			t.Fatal("second signal did not force an exit")
+		}
+		close(release)
+		assert.Equal(t, ExitOK, <-done)
+	}
+}
+
+func TestRun_SignalDuringInit(t *testing.T) {
+	initializing := make(chan struct{})
+	release := make(chan struct{})
+	defer close(release)
+	app, err := New("")
+	if assert.NoError(t, err) {
+		app.Add(MakeModule(Name("hanging"), Init(func(_ Application) error {
+			close(initializing)
+			<-release
+			return nil
+		})))
+
+		go func() {
+			<-initializing
+			syscall.Kill(syscall.Getpid(), syscall.SIGINT)
+		}()
+
+		done := make(chan int)
+		go func() { done <- app.Run() }()
+
+		select {
+		case code := <-done:
+			assert.Equal(t, ExitOK, code)
+		case <-time.After(5 * time.Second):
+			t.Fatal("signal did not interrupt the startup")
+		}
+	}
+}
+
+func TestRun_ForcedExitDuringStart(t *testing.T) {
+	oldExit := exit
+	defer func() { exit = oldExit }()
+	exited := make(chan int, 1)
+	exit = func(code int) { exited <- code }
+
+	starting := make(chan struct{})
+	stopping := make(chan struct{})
+	release := make(chan struct{})
+	defer close(release)
+	app, err := New("")
+	if assert.NoError(t, err) {
+		app.Add(MakeModule(Name("hanging"),
+			Start(func(_ Application) error {
+				close(starting)
+				<-release
+				return nil
+			}),
+			Stop(func(_ Application) error {
+				close(stopping)
+				<-release
+				return nil
+			}),
+		))
+
+		go func() {
+			<-starting
+			syscall.Kill(syscall.Getpid(), syscall.SIGINT)
+			<-stopping
+			syscall.Kill(syscall.Getpid(), syscall.SIGINT)
+		}()
+
+		go app.Run()
+
+		select {
+		case code := <-exited:
+			assert.Equal(t, ExitForced, code)
+		case <-time.After(5 * time.Second):
+			t.Fatal("second signal did not force an exit")
+		}
+	}
+}
@@ -0,0 +1,236 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SecretResolver returns the secret a reference in a config value points to,
+// for ${env:DB_PASS} the env resolver gets DB_PASS as reference.
+type SecretResolver func(ref string) (string, error)
+
+// secretCommandTimeout is how long CommandSecret waits for a command
+const secretCommandTimeout = 10 * time.Second
+
+// the resolvers for env and file are registered by default, commands are opt-in with CommandSecret
+var (
+	secretResolversLock sync.Mutex
+	secretResolvers     = map[string]SecretResolver{
+		"env":  envSecret,
+		"file": fileSecret,
+	}
+)
+
+// RegisterSecretResolver for use in config values as ${scheme:reference}.
+// When you register a resolver with a scheme that was already present
+// then that resolver will get overwritten
+func RegisterSecretResolver(scheme string, resolver SecretResolver) {
+	secretResolversLock.Lock()
+	secretResolvers[strings.ToLower(scheme)] = resolver
+	secretResolversLock.Unlock()
+}
+
+// KnownSecretResolvers returns the sorted schemes of the registered secret resolvers
+func KnownSecretResolvers() []string {
+	secretResolversLock.Lock()
+	defer secretResolversLock.Unlock()
+
+	schemes := make([]string, 0, len(secretResolvers))
+	for k := range secretResolvers {
+		schemes = append(schemes, k)
+	}
+	sort.Strings(schemes)
+	return schemes
+}
+
+func secretResolver(scheme string) (SecretResolver, bool) {
+	secretResolversLock.Lock()
+	defer secretResolversLock.Unlock()
+	resolver, ok := secretResolvers[strings.ToLower(scheme)]
+	return resolver, ok
+}
+
+// envSecret resolves ${env:NAME} to the value of the environment variable
+func envSecret(name string) (string, error) {
+	value, ok := os.LookupEnv(name)
+	if !ok {
+		return "", fmt.Errorf("environment variable %s is not set", name)
+	}
+	return value, nil
+}
+
+// fileSecret resolves ${file:/run/secrets/db} to the content of the file, without the trailing line break
+func fileSecret(path string) (string, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(b), "\r\n"), nil
+}
+
+// CommandSecret resolves a reference to the output of the command, without the trailing line break.
+// The command isn't run by a shell, its arguments are separated by spaces. It isn't registered by default,
+// because anyone who can change a config file can then run commands:
+//
+//	app.RegisterSecretResolver("cmd", app.CommandSecret)  // ${cmd:pass show orders/db}
+func CommandSecret(command string) (string, error) {
+	args := strings.Fields(command)
+	if len(args) == 0 {
+		return "", errors.New("no command")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), secretCommandTimeout)
+	defer cancel()
+
+	out, err := exec.CommandContext(ctx, args[0], args[1:]...).Output()
+	if err != nil {
+		// the output isn't part of the error, it could contain the secret
+		return "", fmt.Errorf("running %s: %v", args[0], err)
+	}
+	return strings.TrimRight(string(out), "\r\n"), nil
+}
+
+// secretRef matches ${scheme:reference}, $${ escapes a literal ${
+var secretRef = regexp.MustCompile(`\$\$\{|\$\{([a-zA-Z][a-zA-Z0-9_-]*):([^}]*)\}`)
+
+// resolvedSecrets returns a copy of the layers with the secrets in the settings of local sources resolved,
+// and the keys of the values with a secret that no layer above overrides. The settings of remote sources are used
+// as they are, so whoever can write to the remote config can't read files on the hosts of the application.
+func (l configLayers) resolvedSecrets() (configLayers, sensitiveKeys, error) {
+	res := make(configLayers, len(l))
+	copy(res, l)
+	sensitive := make(sensitiveKeys)
+	var errs []error
+	for i := range res {
+		if _, remote := res[i].source.(*remoteSource); remote || res[i].settings == nil {
+			continue
+		}
+		settings, keys, err := resolveSecrets(res[i].settings)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", res[i].name, err))
+			continue
+		}
+		res[i].settings = settings
+		for key := range keys {
+			if _, overridden := res[i+1:].origin(key); !overridden {
+				sensitive[key] = true
+			}
+		}
+	}
+	if err := joinErrors(errs); err != nil {
+		return nil, nil, err
+	}
+	return res, sensitive, nil
+}
+
+// resolveSecrets returns a copy of the settings with the secret references in their values resolved,
+// and the keys of the values that contain a secret.
+func resolveSecrets(settings map[string]interface{}) (map[string]interface{}, sensitiveKeys, error) {
+	r := &secretResolution{sensitive: make(sensitiveKeys)}
+	resolved := r.resolveMap("", settings)
+	if err := joinErrors(r.errs); err != nil {
+		return nil, nil, err
+	}
+	return resolved, r.sensitive, nil
+}
+
+type secretResolution struct {
+	sensitive sensitiveKeys
+	errs      []error
+}
+
+func (r *secretResolution) resolveMap(prefix string, settings map[string]interface{}) map[string]interface{} {
+	res := make(map[string]interface{}, len(settings))
+	for k, v := range settings {
+		key := strings.ToLower(k)
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+		res[k] = r.resolveValue(key, key, v)
+	}
+	return res
+}
+
+// resolveValue resolves the references in the value at the path, values in lists mark the key of the list sensitive
+func (r *secretResolution) resolveValue(key, path string, value interface{}) interface{} {
+	if m, ok := toStringMap(value); ok {
+		if key != path {
+			// maps in lists are marked as a whole
+			res := make(map[string]interface{}, len(m))
+			for k, v := range m {
+				res[k] = r.resolveValue(key, path+"."+strings.ToLower(k), v)
+			}
+			return res
+		}
+		return r.resolveMap(key, m)
+	}
+
+	switch v := value.(type) {
+	case []interface{}:
+		res := make([]interface{}, len(v))
+		for i, vv := range v {
+			res[i] = r.resolveValue(key, fmt.Sprintf("%s[%d]", path, i), vv)
+		}
+		return res
+	case string:
+		return r.resolveString(key, path, v)
+	default:
+		return v
+	}
+}
+
+func (r *secretResolution) resolveString(key, path, value string) string {
+	if !strings.Contains(value, "${") {
+		return value
+	}
+	secret := false
+	resolved := secretRef.ReplaceAllStringFunc(value, func(ref string) string {
+		if ref == "$${" {
+			return "${"
+		}
+		parts := secretRef.FindStringSubmatch(ref)
+		resolver, ok := secretResolver(parts[1])
+		if !ok {
+			r.errs = append(r.errs, fmt.Errorf("%s: unknown secret resolver %q, use one of %s", path, parts[1], strings.Join(KnownSecretResolvers(), ", ")))
+			return ref
+		}
+		s, err := resolver(parts[2])
+		if err != nil {
+			r.errs = append(r.errs, fmt.Errorf("%s: resolving %s secret: %v", path, parts[1], err))
+			return ref
+		}
+		secret = true
+		return s
+	})
+	if secret {
+		r.sensitive[key] = true
+	}
+	return resolved
+}
+
+// sensitiveKeys are the keys of the config with a value that contains a secret
+type sensitiveKeys map[string]bool
+
+// contains is true for the key of a secret and for the keys of the subtrees a secret is in
+func (s sensitiveKeys) contains(key string) bool {
+	key = strings.ToLower(key)
+	for k := range s {
+		if inSubtree(k, key) {
+			return true
+		}
+	}
+	return false
+}
+
+func (d *defaultApplication) IsSensitive(key string) bool {
+	sensitive := d.sensitive.Load()
+	return sensitive != nil && sensitive.contains(key)
+}
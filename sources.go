@@ -0,0 +1,250 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/spf13/pflag"
+)
+
+// ConfigSource provides a layer of the config of the application
+type ConfigSource interface {
+	// Name of the source, it is reported as the origin of the keys it sets, eg. the path of a file
+	Name() string
+
+	// Load reads the settings of the source, nested maps are nested keys
+	Load() (map[string]interface{}, error)
+
+	// Watch calls changed whenever the source changes, it returns when the context is done.
+	// Sources that never change return nil right away, a watch that fails is started again.
+	Watch(ctx context.Context, changed func()) error
+}
+
+// Priority orders the config sources, the settings of a source with a higher priority win
+type Priority int
+
+const (
+	// PriorityDefaults is for defaults that are embedded in the application
+	PriorityDefaults Priority = 100
+	// PriorityFile is for config files
+	PriorityFile Priority = 200
+	// PriorityProfile is for the config file of the active profile, it overrides the config file
+	PriorityProfile Priority = 250
+	// PriorityEnv is for environment variables
+	PriorityEnv Priority = 300
+	// PriorityFlags is for command line flags
+	PriorityFlags Priority = 400
+	// PriorityRemote is for remote config providers like etcd and consul
+	PriorityRemote Priority = 500
+)
+
+func (d *defaultApplication) AddConfigSource(source ConfigSource, priority Priority) error {
+	settings, err := source.Load()
+	if err != nil {
+		return fmt.Errorf("loading config from %s: %w", source.Name(), err)
+	}
+
+	layer := d.newLayer(source, priority)
+	layer.settings = settings
+	evt := fsnotify.Event{Name: source.Name(), Op: fsnotify.Create}
+	if err := d.updateLayers(evt, func(layers configLayers) configLayers { return layers.with(layer) }, nil); err != nil {
+		return err
+	}
+	d.watchSource(d.watchContext(), layer)
+	return nil
+}
+
+func (d *defaultApplication) ConfigOrigin(key string) string {
+	d.reloadLock.Lock()
+	defer d.reloadLock.Unlock()
+	name, _ := d.layers.origin(key)
+	return name
+}
+
+// newLayer assigns the source the next id
+func (d *defaultApplication) newLayer(source ConfigSource, priority Priority) configLayer {
+	d.regLock.Lock()
+	defer d.regLock.Unlock()
+	d.sourceIDs++
+	return configLayer{id: d.sourceIDs, name: source.Name(), priority: priority, source: source}
+}
+
+// EmbeddedSource is a config document that is compiled into the application, eg. with go:embed.
+// The type of the document is taken from the extension of the name.
+func EmbeddedSource(name string, data []byte) ConfigSource {
+	return &embeddedSource{name: name, data: data}
+}
+
+type embeddedSource struct {
+	name string
+	data []byte
+}
+
+func (e *embeddedSource) Name() string { return e.name }
+
+func (e *embeddedSource) Load() (map[string]interface{}, error) {
+	return parseSettings(bytes.NewReader(e.data), strings.ToLower(strings.TrimLeft(filepath.Ext(e.name), ".")))
+}
+
+func (e *embeddedSource) Watch(_ context.Context, _ func()) error {
+	return nil
+}
+
+// FileSource is a config file, the type is taken from its extension.
+// The directory of the file is watched, editors often replace the file instead of writing to it.
+func FileSource(path string) ConfigSource {
+	return &fileSource{path: filepath.Clean(path)}
+}
+
+type fileSource struct {
+	path string
+}
+
+func (f *fileSource) Name() string { return f.path }
+
+func (f *fileSource) Load() (map[string]interface{}, error) {
+	return readSettingsFile(f.path)
+}
+
+func (f *fileSource) Watch(ctx context.Context, changed func()) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer watcher.Close()
+	if err := watcher.Add(filepath.Dir(f.path)); err != nil {
+		return err
+	}
+
+	for {
+		select {
+		case evt, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			if filepath.Clean(evt.Name) == f.path && evt.Op&(fsnotify.Write|fsnotify.Create) != 0 {
+				changed()
+			}
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			return err
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// EnvSource reads the environment variables that start with the prefix and an underscore.
+// The prefix is upper cased and every character other than a letter or a digit becomes an underscore,
+// so the prefix for go-app is GO_APP_. The rest of the name is the key, a double underscore separates nested keys:
+//
+//	ORDERS_DB__URL=postgres://localhost/orders  ->  db.url
+//
+// The environment is only read when the source is added, eg. with WithConfigSource(EnvSource("orders"), PriorityEnv).
+func EnvSource(prefix string) ConfigSource {
+	return &envSource{prefix: envPrefix(prefix)}
+}
+
+// envPrefix turns the prefix into the start of an environment variable name
+func envPrefix(prefix string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' {
+			return r
+		}
+		return '_'
+	}, strings.ToUpper(prefix)) + "_"
+}
+
+type envSource struct {
+	prefix string
+}
+
+func (e *envSource) Name() string { return "env:" + e.prefix + "*" }
+
+func (e *envSource) Load() (map[string]interface{}, error) {
+	settings := make(map[string]interface{})
+	for _, kv := range os.Environ() {
+		parts := strings.SplitN(kv, "=", 2)
+		if len(parts) != 2 || !strings.HasPrefix(parts[0], e.prefix) || len(parts[0]) == len(e.prefix) {
+			continue
+		}
+		key := strings.ToLower(strings.Replace(parts[0][len(e.prefix):], "__", ".", -1))
+		setPath(settings, strings.Split(key, "."), parts[1])
+	}
+	return settings, nil
+}
+
+func (e *envSource) Watch(_ context.Context, _ func()) error {
+	return nil
+}
+
+// FlagSource reads the flags that were set on the command line, the name of a flag is the key, eg. --orders.port
+func FlagSource(flags *pflag.FlagSet) ConfigSource {
+	return &flagSource{flags: flags}
+}
+
+type flagSource struct {
+	flags *pflag.FlagSet
+}
+
+func (f *flagSource) Name() string { return "flags" }
+
+func (f *flagSource) Load() (map[string]interface{}, error) {
+	settings := make(map[string]interface{})
+	f.flags.Visit(func(flag *pflag.Flag) {
+		var value interface{} = flag.Value.String()
+		if slice, ok := flag.Value.(pflag.SliceValue); ok {
+			value = slice.GetSlice()
+		}
+		setPath(settings, strings.Split(strings.ToLower(flag.Name), "."), value)
+	})
+	return settings, nil
+}
+
+func (f *flagSource) Watch(_ context.Context, _ func()) error {
+	return nil
+}
+
+// RemoteSource reads the config from etcd or consul, the url has the same format as CONFIG_REMOTE_URL.
+// When the keyring is not empty the config is expected to be encrypted with its public key.
+func RemoteSource(url, keyring string) (ConfigSource, error) {
+	rp, err := parseRemoteURL(url, keyring)
+	if err != nil {
+		return nil, err
+	}
+	return &remoteSource{remote: rp}, nil
+}
+
+type remoteSource struct {
+	remote *remoteProvider
+}
+
+func (r *remoteSource) Name() string { return r.remote.url }
+
+func (r *remoteSource) Load() (map[string]interface{}, error) {
+	return r.remote.fetch()
+}
+
+func (r *remoteSource) Watch(ctx context.Context, changed func()) error {
+	return r.remote.watch(ctx, changed)
+}
+
+// setPath sets the value at the path of nested maps, the maps are created when they don't exist yet
+func setPath(settings map[string]interface{}, path []string, value interface{}) {
+	for _, part := range path[:len(path)-1] {
+		next, ok := settings[part].(map[string]interface{})
+		if !ok {
+			next = make(map[string]interface{})
+			settings[part] = next
+		}
+		settings = next
+	}
+	settings[path[len(path)-1]] = value
+}
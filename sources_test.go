@@ -0,0 +1,206 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/assert"
+)
+
+// memorySource is a config source that changes when the test sets new settings
+type memorySource struct {
+	name     string
+	lock     sync.Mutex
+	settings map[string]interface{}
+	err      error
+	changes  chan struct{}
+}
+
+func newMemorySource(name string, settings map[string]interface{}) *memorySource {
+	return &memorySource{name: name, settings: settings, changes: make(chan struct{}, 1)}
+}
+
+func (m *memorySource) Name() string { return m.name }
+
+func (m *memorySource) Load() (map[string]interface{}, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return m.settings, m.err
+}
+
+func (m *memorySource) Watch(ctx context.Context, changed func()) error {
+	for {
+		select {
+		case <-m.changes:
+			changed()
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+func (m *memorySource) set(settings map[string]interface{}) {
+	m.lock.Lock()
+	m.settings = settings
+	m.lock.Unlock()
+	m.changes <- struct{}{}
+}
+
+func TestSources_EnvSource(t *testing.T) {
+	os.Setenv("ORDERS_PORT", "8080")
+	os.Setenv("ORDERS_DB__URL", "postgres://localhost/orders")
+	os.Setenv("ORDERS_", "ignored")
+	defer os.Unsetenv("ORDERS_PORT")
+	defer os.Unsetenv("ORDERS_DB__URL")
+	defer os.Unsetenv("ORDERS_")
+
+	src := EnvSource("orders")
+	settings, err := src.Load()
+	if assert.NoError(t, err) {
+		assert.Equal(t, map[string]interface{}{
+			"port": "8080",
+			"db":   map[string]interface{}{"url": "postgres://localhost/orders"},
+		}, settings)
+	}
+	assert.Equal(t, "env:ORDERS_*", src.Name())
+	assert.Equal(t, "env:GO_APP_*", EnvSource("go-app").Name())
+}
+
+func TestSources_EnvOptIn(t *testing.T) {
+	// kubernetes sets variables like this one for a service with the same name as the application
+	os.Setenv("ENVTEST_PORT", "tcp://10.0.0.1:8080")
+	defer os.Unsetenv("ENVTEST_PORT")
+
+	app, err := NewWithOptions("envtest", WithSignalHandling(false), WithWatchers(false))
+	if assert.NoError(t, err) {
+		assert.False(t, app.Config().IsSet("port"))
+	}
+
+	app, err = NewWithOptions("envtest", WithSignalHandling(false), WithWatchers(false), WithConfigSource(EnvSource("envtest"), PriorityEnv))
+	if assert.NoError(t, err) {
+		assert.Equal(t, "tcp://10.0.0.1:8080", app.Config().GetString("port"))
+		assert.Equal(t, "env:ENVTEST_*", app.ConfigOrigin("port"))
+	}
+}
+
+func TestSources_FlagSource(t *testing.T) {
+	flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	flags.Int("orders.port", 8080, "")
+	flags.String("orders.db", "postgres", "")
+	flags.StringSlice("tags", nil, "")
+	if assert.NoError(t, flags.Parse([]string{"--orders.port", "9090", "--tags", "a,b"})) {
+		settings, err := FlagSource(flags).Load()
+		if assert.NoError(t, err) {
+			// flags that aren't set don't override other sources
+			assert.Equal(t, map[string]interface{}{
+				"orders": map[string]interface{}{"port": "9090"},
+				"tags":   []string{"a", "b"},
+			}, settings)
+		}
+	}
+}
+
+func TestSources_EmbeddedSource(t *testing.T) {
+	settings, err := EmbeddedSource("defaults.yaml", []byte("orders:\n  port: 8080\n")).Load()
+	if assert.NoError(t, err) {
+		assert.Equal(t, map[string]interface{}{"orders": map[string]interface{}{"port": 8080}}, settings)
+	}
+
+	_, err = EmbeddedSource("defaults.json", []byte("{")).Load()
+	assert.Error(t, err)
+}
+
+func TestSources_Priority(t *testing.T) {
+	app, err := New("")
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	defaults := EmbeddedSource("defaults.yaml", []byte("orders:\n  port: 8080\n  db: sqlite\n"))
+	if assert.NoError(t, app.AddConfigSource(defaults, PriorityDefaults)) {
+		assert.Equal(t, 8080, app.Config().GetInt("orders.port"))
+		assert.Equal(t, "defaults.yaml", app.ConfigOrigin("orders.port"))
+	}
+
+	remote := newMemorySource("remote", map[string]interface{}{"orders": map[string]interface{}{"db": "postgres"}})
+	if assert.NoError(t, app.AddConfigSource(remote, PriorityRemote)) {
+		assert.Equal(t, "postgres", app.Config().GetString("orders.db"))
+		assert.Equal(t, "remote", app.ConfigOrigin("orders.db"))
+		assert.Equal(t, "defaults.yaml", app.ConfigOrigin("orders.port"))
+	}
+
+	// a source added later with a lower priority doesn't override the remote config
+	flags := newMemorySource("flags", map[string]interface{}{"orders": map[string]interface{}{"db": "mysql", "port": 9090}})
+	if assert.NoError(t, app.AddConfigSource(flags, PriorityFlags)) {
+		assert.Equal(t, "postgres", app.Config().GetString("orders.db"))
+		assert.Equal(t, 9090, app.Config().GetInt("orders.port"))
+		assert.Equal(t, "flags", app.ConfigOrigin("orders.port"))
+	}
+	assert.Empty(t, app.ConfigOrigin("orders.unknown"))
+
+	broken := newMemorySource("broken", nil)
+	broken.err = errors.New("unavailable")
+	assert.EqualError(t, app.AddConfigSource(broken, PriorityFile), "loading config from broken: unavailable")
+}
+
+func TestSources_WatchSource(t *testing.T) {
+	app, err := New("")
+	if !assert.NoError(t, err) {
+		return
+	}
+	rec := new(eventRecorder)
+	app.Subscribe(rec.handle, EventConfigChanged)
+
+	src := newMemorySource("memory", map[string]interface{}{"name": "first"})
+	if assert.NoError(t, app.AddConfigSource(src, PriorityFile)) {
+		src.set(map[string]interface{}{"name": "second"})
+		assert.Eventually(t, func() bool { return len(rec.recorded()) == 2 }, 2*time.Second, 10*time.Millisecond)
+
+		events := rec.recorded()
+		if assert.Len(t, events, 2) {
+			assert.Equal(t, "memory", events[1].Source)
+			assert.Equal(t, []string{"name"}, events[1].Keys)
+		}
+		assert.Equal(t, "second", app.Config().GetString("name"))
+	}
+}
+
+func TestSources_FromLifecycle(t *testing.T) {
+	app, err := NewWithOptions("sources", WithSignalHandling(false), WithWatchers(false))
+	if !assert.NoError(t, err) {
+		return
+	}
+	rec := new(eventRecorder)
+	app.Subscribe(rec.handle, EventConfigChanged)
+
+	var origin string
+	initSource := newMemorySource("init", map[string]interface{}{"orders": map[string]interface{}{"port": 8080}})
+	reloadSource := newMemorySource("reload", map[string]interface{}{"payments": map[string]interface{}{"port": 9090}})
+	app.Add(MakeModule(Name("orders"), Watches("orders"),
+		Init(func(a Application) error {
+			return a.AddConfigSource(initSource, PriorityRemote)
+		}),
+		Reload(func(a Application) error {
+			origin = a.ConfigOrigin("orders.port")
+			return a.AddConfigSource(reloadSource, PriorityRemote)
+		}),
+	))
+
+	if finishes(t, func() { err = app.Init() }) {
+		assert.NoError(t, err)
+		// the source init added is reloaded after the init phase, and the source reload added after that reload
+		assert.Eventually(t, func() bool { return len(rec.recorded()) == 2 }, 2*time.Second, 10*time.Millisecond)
+		if evts := rec.recorded(); assert.Len(t, evts, 2) {
+			assert.Equal(t, "init", evts[0].Source)
+			assert.Equal(t, "reload", evts[1].Source)
+		}
+		assert.Equal(t, "init", origin)
+		assert.Equal(t, 9090, app.Config().GetInt("payments.port"))
+		assert.NoError(t, app.Close())
+	}
+}
@@ -0,0 +1,244 @@
+package app
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// DefaultReloadDebounce is how long the config needs to be quiet before it gets reloaded, when it isn't configured
+const DefaultReloadDebounce = 100 * time.Millisecond
+
+// configChange is a change reported by the watch of the source with the id
+type configChange struct {
+	event fsnotify.Event
+	id    int
+}
+
+// reloadDebounce is the quiet period configured as
+//
+//	config:
+//	  debounce: 500ms
+func (d *defaultApplication) reloadDebounce() time.Duration {
+	d.reloadLock.Lock()
+	defer d.reloadLock.Unlock()
+
+	if !d.config.IsSet("config.debounce") {
+		return DefaultReloadDebounce
+	}
+	return d.config.GetDuration("config.debounce")
+}
+
+// watchConfigurations watches the sources of the config until the application stops watching,
+// unless watching is disabled. The pending changes are applied like the changes the watches report.
+func (d *defaultApplication) watchConfigurations(pending []configChange) {
+	d.reloadLock.Lock()
+	watching, stop := context.WithCancel(context.Background())
+	d.watching, d.stopWatching = watching, stop
+	layers := d.layers
+	d.reloadLock.Unlock()
+
+	if !d.watchSources {
+		return
+	}
+	d.background.Add(1)
+	go func() {
+		defer d.background.Done()
+		d.debounceChanges(watching, d.changes, pending)
+	}()
+
+	for _, layer := range layers {
+		d.watchSource(watching, layer)
+	}
+}
+
+// resumeWatching watches the sources of the config again when the application stopped watching them,
+// the sources are reloaded to catch up with the changes that were made in the meantime
+func (d *defaultApplication) resumeWatching() {
+	d.reloadLock.Lock()
+	resume := !d.closed && d.watching.Err() != nil
+	layers := d.layers
+	d.reloadLock.Unlock()
+	if !resume {
+		return
+	}
+
+	pending := make([]configChange, 0, len(layers))
+	for _, layer := range layers {
+		pending = append(pending, configChange{event: fsnotify.Event{Name: layer.name, Op: fsnotify.Write}, id: layer.id})
+	}
+	d.watchConfigurations(pending)
+}
+
+// pauseWatching stops watching the sources of the config
+func (d *defaultApplication) pauseWatching() {
+	d.reloadLock.Lock()
+	d.stopWatching()
+	d.reloadLock.Unlock()
+}
+
+// watchContext is done when the application stops watching the sources of the config
+func (d *defaultApplication) watchContext() context.Context {
+	d.reloadLock.Lock()
+	defer d.reloadLock.Unlock()
+	return d.watching
+}
+
+// watchSource watches the source of the layer. A watch that fails is retried with a backoff, the source is
+// reported unavailable once and available again when it can be loaded, which reloads it to catch up with
+// the changes that were missed in the meantime.
+func (d *defaultApplication) watchSource(watching context.Context, layer configLayer) {
+	if !d.watchSources {
+		return
+	}
+	changed := func() {
+		select {
+		case d.changes <- configChange{event: fsnotify.Event{Name: layer.name, Op: fsnotify.Write}, id: layer.id}:
+		case <-watching.Done():
+		}
+	}
+	d.background.Add(1)
+	go func() {
+		defer d.background.Done()
+		// the backoff keeps growing while the watch keeps failing, a watch that ran for longer than the longest wait
+		// recovered from the outage, so its next failure starts over with the initial wait and is logged again
+		retry := d.watchBackoff()
+		var unavailable, recovered bool
+		for {
+			began := time.Now()
+			err := layer.source.Watch(watching, changed)
+			if err == nil || watching.Err() != nil {
+				return
+			}
+			if time.Since(began) >= retry.max {
+				retry.reset()
+				unavailable = false
+			}
+			if !unavailable {
+				d.Logger().Errorf("config from %s is unavailable, retrying: %v", layer.name, err)
+				unavailable, recovered = true, false
+			} else {
+				d.Logger().Debugf("watch of config from %s failed again: %v", layer.name, err)
+			}
+
+			for {
+				if !sleep(watching, retry.next()) {
+					return
+				}
+				if _, err := layer.source.Load(); err != nil {
+					d.Logger().Debugf("config from %s is still unavailable: %v", layer.name, err)
+					continue
+				}
+				break
+			}
+			if !recovered {
+				d.Logger().Infof("config from %s is available again", layer.name)
+				recovered = true
+			}
+			changed()
+		}
+	}()
+}
+
+// sleep waits for the duration, it returns false when watching stopped before that
+func sleep(watching context.Context, wait time.Duration) bool {
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-watching.Done():
+		return false
+	}
+}
+
+// debounceChanges waits until no changes arrived for the debounce period, then applies all of them at once.
+// A burst of writes to the config file, eg. from an editor, results in a single reload of the fully written file.
+func (d *defaultApplication) debounceChanges(watching context.Context, changes <-chan configChange, pending []configChange) {
+	var (
+		timer *time.Timer
+		fire  <-chan time.Time
+	)
+	if len(pending) > 0 {
+		timer = time.NewTimer(d.reloadDebounce())
+		fire = timer.C
+	}
+	for {
+		select {
+		case change := <-changes:
+			pending = append(pending, change)
+			if timer == nil {
+				timer = time.NewTimer(d.reloadDebounce())
+				fire = timer.C
+				continue
+			}
+			if !timer.Stop() {
+				<-timer.C
+			}
+			timer.Reset(d.reloadDebounce())
+		case <-fire:
+			timer, fire = nil, nil
+			evt, ids := coalesce(pending)
+			pending = nil
+
+			settings := d.loadSources(ids)
+			if len(settings) == 0 {
+				// the next change of the source triggers another attempt
+				continue
+			}
+			d.updateLayers(evt, func(layers configLayers) configLayers { return layers.replaced(settings) }, d.reloadHook)
+		case <-watching.Done():
+			if timer != nil {
+				timer.Stop()
+			}
+			return
+		}
+	}
+}
+
+// loadSources loads the sources with the ids, a source that fails to load keeps its previous settings
+func (d *defaultApplication) loadSources(ids []int) map[int]map[string]interface{} {
+	settings := make(map[int]map[string]interface{}, len(ids))
+	for _, layer := range d.currentLayers() {
+		for _, id := range ids {
+			if layer.id != id {
+				continue
+			}
+			loaded, err := layer.source.Load()
+			if err != nil {
+				d.Logger().Errorf("loading config from %s, keeping the previous config: %v", layer.name, err)
+				continue
+			}
+			settings[id] = loaded
+		}
+	}
+	return settings
+}
+
+func (d *defaultApplication) currentLayers() configLayers {
+	d.reloadLock.Lock()
+	defer d.reloadLock.Unlock()
+	return d.layers
+}
+
+// coalesce the changes into a single event, named after every source that changed, and the ids of those sources
+func coalesce(changes []configChange) (fsnotify.Event, []int) {
+	var evt fsnotify.Event
+	var ids []int
+	seen := make(map[int]struct{}, len(changes))
+	var names []string
+	for _, change := range changes {
+		evt.Op |= change.event.Op
+		if _, ok := seen[change.id]; !ok {
+			seen[change.id] = struct{}{}
+			ids = append(ids, change.id)
+			names = append(names, change.event.Name)
+		}
+	}
+	sort.Strings(names)
+	evt.Name = strings.Join(names, ", ")
+	return evt, ids
+}
@@ -0,0 +1,105 @@
+package app
+
+import (
+	"io/ioutil"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWatch_Coalesce(t *testing.T) {
+	evt, ids := coalesce([]configChange{
+		{event: fsnotify.Event{Name: "config.json", Op: fsnotify.Write}, id: 1},
+		{event: fsnotify.Event{Name: "etcd://localhost:2379/app/config.json", Op: fsnotify.Write}, id: 3},
+		{event: fsnotify.Event{Name: "config.json", Op: fsnotify.Create}, id: 1},
+		{event: fsnotify.Event{Name: "etcd://localhost:2379/app/config.json", Op: fsnotify.Write}, id: 3},
+	})
+	assert.Equal(t, []int{1, 3}, ids)
+	assert.Equal(t, "config.json, etcd://localhost:2379/app/config.json", evt.Name)
+	assert.Equal(t, fsnotify.Write|fsnotify.Create, evt.Op)
+}
+
+func TestWatch_DebounceBurst(t *testing.T) {
+	err := ioutil.WriteFile("config.json", []byte(`{"name": "first", "config": {"debounce": "200ms"}}`), 0644)
+	defer os.Remove("config.json")
+
+	if assert.NoError(t, err) {
+		var reloads int32
+		app, err := newWithCallback("", "", func(_ fsnotify.Event) { atomic.AddInt32(&reloads, 1) })
+		if assert.NoError(t, err) {
+			time.Sleep(100 * time.Millisecond)
+			// an editor writing the file in several steps, the intermediate states don't parse
+			assert.NoError(t, ioutil.WriteFile("config.json", []byte(`{"name": "sec`), 0644))
+			time.Sleep(20 * time.Millisecond)
+			assert.NoError(t, ioutil.WriteFile("config.json", []byte(`{"name": "second", "config": {"debounce": "200ms"`), 0644))
+			time.Sleep(20 * time.Millisecond)
+			assert.NoError(t, ioutil.WriteFile("config.json", []byte(`{"name": "second", "config": {"debounce": "200ms"}}`), 0644))
+
+			assert.Eventually(t, func() bool { return atomic.LoadInt32(&reloads) > 0 }, 2*time.Second, 10*time.Millisecond)
+			time.Sleep(300 * time.Millisecond)
+			assert.Equal(t, int32(1), atomic.LoadInt32(&reloads))
+			assert.Equal(t, "second", app.Config().GetString("name"))
+		}
+	}
+}
+
+func TestWatch_BrokenFile(t *testing.T) {
+	err := ioutil.WriteFile("config.json", []byte(`{"name": "first", "config": {"debounce": "50ms"}}`), 0644)
+	defer os.Remove("config.json")
+
+	if assert.NoError(t, err) {
+		var reloads int32
+		app, err := newWithCallback("", "", func(_ fsnotify.Event) { atomic.AddInt32(&reloads, 1) })
+		if assert.NoError(t, err) {
+			time.Sleep(100 * time.Millisecond)
+			assert.NoError(t, ioutil.WriteFile("config.json", []byte(`{"name": `), 0644))
+
+			time.Sleep(300 * time.Millisecond)
+			assert.Equal(t, int32(0), atomic.LoadInt32(&reloads))
+			assert.Equal(t, "first", app.Config().GetString("name"))
+		}
+	}
+}
+
+func TestWatch_Restart(t *testing.T) {
+	src := newMemorySource("memory", map[string]interface{}{"name": "first"})
+	app, err := NewWithOptions("watch",
+		WithConfigSource(EmbeddedSource("settings.yaml", []byte("config:\n  debounce: 10ms\n")), PriorityDefaults),
+		WithConfigSource(src, PriorityRemote),
+		WithSignalHandling(false),
+	)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer app.Close()
+	d := app.(*defaultApplication)
+	name := func() string {
+		d.reloadLock.Lock()
+		defer d.reloadLock.Unlock()
+		return d.config.GetString("name")
+	}
+
+	assert.NoError(t, app.Init())
+	assert.NoError(t, app.Start())
+	assert.NoError(t, app.Stop())
+	assert.Error(t, d.watchContext().Err(), "a stopped application doesn't watch its config")
+
+	// the change made while the application was stopped is picked up when it is initialized again
+	src.set(map[string]interface{}{"name": "second"})
+	assert.NoError(t, app.Init())
+	assert.Eventually(t, func() bool { return name() == "second" }, 2*time.Second, 10*time.Millisecond)
+
+	// and it follows the config again
+	assert.NoError(t, app.Start())
+	src.set(map[string]interface{}{"name": "third"})
+	assert.Eventually(t, func() bool { return name() == "third" }, 2*time.Second, 10*time.Millisecond)
+
+	// a closed application doesn't watch its config again
+	assert.NoError(t, app.Close())
+	assert.NoError(t, app.Init())
+	assert.Error(t, d.watchContext().Err())
+}